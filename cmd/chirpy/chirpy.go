@@ -4,18 +4,36 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
 
+	"github.com/AxterDoesCode/webserver/internal/audit"
+	"github.com/AxterDoesCode/webserver/internal/blobstore"
+	"github.com/AxterDoesCode/webserver/internal/contentfilter"
 	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/internal/scheduler"
+	"github.com/AxterDoesCode/webserver/internal/trends"
 	"github.com/AxterDoesCode/webserver/pkg/apiconfig"
 	"github.com/AxterDoesCode/webserver/pkg/httphandler"
 	"github.com/AxterDoesCode/webserver/pkg/middleware"
 )
 
+// auditLogPath is where the audit log is kept, next to the database.
+const auditLogPath = "audit.log"
+
 func main() {
 	godotenv.Load()
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	const port = "8080"
 	r := chi.NewRouter()
 	apiRouter := chi.NewRouter()
@@ -24,10 +42,44 @@ func main() {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	polkaKey := os.Getenv("POLKA_KEY")
 
+	var chirpEditWindow time.Duration
+	if window := os.Getenv("CHIRP_EDIT_WINDOW"); window != "" {
+		var err error
+		chirpEditWindow, err = time.ParseDuration(window)
+		if err != nil {
+			log.Fatalf("CHIRP_EDIT_WINDOW: %s", err)
+		}
+	}
+
+	chirpMaxLength := positiveIntFromEnv("CHIRP_MAX_LENGTH", 140)
+	chirpMaxLengthRed := positiveIntFromEnv("CHIRP_MAX_LENGTH_RED", 280)
+	pinLimit := positiveIntFromEnv("CHIRP_PIN_LIMIT", 1)
+	pinLimitRed := positiveIntFromEnv("CHIRP_PIN_LIMIT_RED", 5)
+
+	var auditRetention time.Duration
+	if os.Getenv("AUDIT_RETENTION_DAYS") != "" {
+		auditRetention = time.Duration(positiveIntFromEnv("AUDIT_RETENTION_DAYS", 0)) * 24 * time.Hour
+	}
+
+	trendsInterval := time.Minute
+	if interval := os.Getenv("TRENDS_REFRESH_INTERVAL"); interval != "" {
+		var err error
+		trendsInterval, err = time.ParseDuration(interval)
+		if err != nil || trendsInterval <= 0 {
+			log.Fatalf("TRENDS_REFRESH_INTERVAL: must be a positive duration")
+		}
+	}
+
 	apiCfg := apiconfig.ApiConfig{
-		FileserverHits: 0,
-		JwtSecret:      jwtSecret,
-		PolkaKey:       polkaKey,
+		FileserverHits:       0,
+		JwtSecret:            jwtSecret,
+		PolkaKey:             polkaKey,
+		ChirpMaxLength:       chirpMaxLength,
+		ChirpMaxLengthRed:    chirpMaxLengthRed,
+		PinLimit:             pinLimit,
+		PinLimitRed:          pinLimitRed,
+		ChirpEditWindow:      chirpEditWindow,
+		ChirpEditRequiresRed: os.Getenv("CHIRP_EDIT_RED_ONLY") == "true",
 	}
 
 	db, err := database.NewDB(".")
@@ -37,6 +89,58 @@ func main() {
 
 	apiCfg.Database = *db
 
+	contentFilterPath := os.Getenv("CONTENT_FILTER_CONFIG")
+	if contentFilterPath == "" {
+		contentFilterPath = "content_filter.json"
+	}
+	contentFilter, err := contentfilter.NewReloader(contentFilterPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiCfg.ContentFilter = contentFilter
+
+	apiCfg.MediaMaxBytes = int64(positiveIntFromEnv("MEDIA_MAX_BYTES", 5<<20))
+	if os.Getenv("MEDIA_STORE") == "s3" {
+		apiCfg.BlobStore = blobstore.NewS3Store(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY_ID"),
+			os.Getenv("S3_SECRET_ACCESS_KEY"),
+		)
+	} else {
+		mediaDir := os.Getenv("MEDIA_DIR")
+		if mediaDir == "" {
+			mediaDir = "media"
+		}
+		apiCfg.BlobStore, err = blobstore.NewLocalStore(mediaDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	go contentFilter.Run(5*time.Second, nil, func(err error) {
+		log.Printf("Reloading content filter: %s", err)
+	})
+
+	apiCfg.Audit, err = audit.Open(auditLogPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if auditRetention > 0 {
+		go apiCfg.Audit.RunRetention(auditRetention, time.Hour, nil, func(err error) {
+			log.Printf("Pruning audit log: %s", err)
+		})
+	}
+
+	apiCfg.Scheduler = scheduler.New(db)
+	go apiCfg.Scheduler.Run(nil, nil, func(err error) {
+		log.Printf("Running scheduler: %s", err)
+	})
+	apiCfg.Trends = trends.NewAggregator(db, trends.SystemClock{}, trends.DefaultWindows)
+	go apiCfg.Trends.Run(trendsInterval, nil, func(err error) {
+		log.Printf("Refreshing trends: %s", err)
+	})
+
 	r.Handle(
 		"/app/*",
 		apiCfg.MiddlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir("./app")))),
@@ -45,13 +149,34 @@ func main() {
 		"/app",
 		apiCfg.MiddlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir("./app")))),
 	)
+	apiRouter.Use(apiCfg.MiddlewareAuditImpersonation)
 	r.Mount("/api", apiRouter)
 	r.Mount("/admin", adminRouter)
 
 	apiRouter.Get("/healthz", httphandler.HandlerReadiness)
 	apiRouter.Post("/chirps", apiCfg.PostChirp)
 	apiRouter.Get("/chirps", apiCfg.GetChirps)
+	apiRouter.Get("/chirps/drafts", apiCfg.GetDraftsHandler)
+	apiRouter.Get("/chirps/scheduled", apiCfg.GetScheduledChirpsHandler)
 	apiRouter.Get("/chirps/{chirpID}", apiCfg.GetChirpByID)
+	apiRouter.Patch("/chirps/{chirpID}", apiCfg.EditChirpHandler)
+	apiRouter.Get("/chirps/{chirpID}/history", apiCfg.GetChirpHistoryHandler)
+	apiRouter.Get("/chirps/{chirpID}/thread", apiCfg.GetThreadHandler)
+	apiRouter.Put("/chirps/{chirpID}/schedule", apiCfg.ScheduleChirpHandler)
+	apiRouter.Delete("/chirps/{chirpID}/schedule", apiCfg.UnscheduleChirpHandler)
+	apiRouter.Post("/chirps/{chirpID}/publish", apiCfg.PublishChirpHandler)
+	apiRouter.Post("/chirps/{chirpID}/poll/votes", apiCfg.VoteHandler)
+	apiRouter.Post("/chirps/{chirpID}/like", apiCfg.LikeChirpHandler)
+	apiRouter.Delete("/chirps/{chirpID}/like", apiCfg.UnlikeChirpHandler)
+	apiRouter.Get("/chirps/{chirpID}/likers", apiCfg.GetLikersHandler)
+	apiRouter.Post("/chirps/{chirpID}/rechirp", apiCfg.RechirpHandler)
+	apiRouter.Delete("/chirps/{chirpID}/rechirp", apiCfg.UnrechirpHandler)
+	apiRouter.Post("/chirps/{chirpID}/report", apiCfg.ReportChirpHandler)
+	apiRouter.Post("/chirps/{chirpID}/pin", apiCfg.PinChirpHandler)
+	apiRouter.Delete("/chirps/{chirpID}/pin", apiCfg.UnpinChirpHandler)
+	apiRouter.Post("/chirps/{chirpID}/bookmark", apiCfg.BookmarkChirpHandler)
+	apiRouter.Delete("/chirps/{chirpID}/bookmark", apiCfg.UnbookmarkChirpHandler)
+	apiRouter.Get("/timeline", apiCfg.GetTimelineHandler)
 	apiRouter.Post("/users", apiCfg.AddUser)
 	apiRouter.Post("/login", apiCfg.UserLogin)
 	apiRouter.Put("/users", apiCfg.UpdateUserHandler)
@@ -59,8 +184,70 @@ func main() {
 	apiRouter.Post("/revoke", apiCfg.RevokeHandler)
 	apiRouter.Delete("/chirps/{chirpID}", apiCfg.DeleteChirpByID)
 	apiRouter.Post("/polka/webhooks", apiCfg.UserUpgradeHandler)
+	apiRouter.Get("/users/me/mentions", apiCfg.GetMentionsHandler)
+	apiRouter.Get("/users/me/bookmarks", apiCfg.GetBookmarksHandler)
+	apiRouter.Get("/users/me/warnings", apiCfg.GetWarningsHandler)
+	apiRouter.Get("/users/me/preferences", apiCfg.GetPreferencesHandler)
+	apiRouter.Put("/users/me/preferences", apiCfg.UpdatePreferencesHandler)
+	apiRouter.Get("/users/me/following", apiCfg.GetFollowingHandler)
+	apiRouter.Get("/users/me/followers", apiCfg.GetFollowersHandler)
+	apiRouter.Get("/users/me/blocks", apiCfg.GetBlockedUsersHandler)
+	apiRouter.Get("/users/me/mutes", apiCfg.GetMutedUsersHandler)
+	apiRouter.Post("/users/{userID}/report", apiCfg.ReportUserHandler)
+	apiRouter.Post("/users/{userID}/follow", apiCfg.FollowUserHandler)
+	apiRouter.Delete("/users/{userID}/follow", apiCfg.UnfollowUserHandler)
+	apiRouter.Post("/users/{userID}/block", apiCfg.BlockUserHandler)
+	apiRouter.Delete("/users/{userID}/block", apiCfg.UnblockUserHandler)
+	apiRouter.Post("/users/{userID}/mute", apiCfg.MuteUserHandler)
+	apiRouter.Delete("/users/{userID}/mute", apiCfg.UnmuteUserHandler)
+	apiRouter.Post("/conversations", apiCfg.CreateConversationHandler)
+	apiRouter.Get("/conversations", apiCfg.GetConversationsHandler)
+	apiRouter.Get("/conversations/{conversationID}", apiCfg.GetConversationHandler)
+	apiRouter.Get("/conversations/{conversationID}/messages", apiCfg.GetMessagesHandler)
+	apiRouter.Post("/conversations/{conversationID}/messages", apiCfg.SendMessageHandler)
+	apiRouter.Post("/conversations/{conversationID}/read", apiCfg.MarkConversationReadHandler)
+	apiRouter.Post("/lists", apiCfg.CreateListHandler)
+	apiRouter.Get("/lists", apiCfg.GetListsHandler)
+	apiRouter.Get("/lists/{listID}", apiCfg.GetListHandler)
+	apiRouter.Patch("/lists/{listID}", apiCfg.UpdateListHandler)
+	apiRouter.Delete("/lists/{listID}", apiCfg.DeleteListHandler)
+	apiRouter.Get("/lists/{listID}/chirps", apiCfg.GetListChirpsHandler)
+	apiRouter.Put("/lists/{listID}/members/{userID}", apiCfg.AddListMemberHandler)
+	apiRouter.Delete("/lists/{listID}/members/{userID}", apiCfg.RemoveListMemberHandler)
+	apiRouter.Post("/lists/{listID}/subscription", apiCfg.SubscribeToListHandler)
+	apiRouter.Delete("/lists/{listID}/subscription", apiCfg.UnsubscribeFromListHandler)
+	apiRouter.Get("/tags/{tag}/chirps", apiCfg.GetTagChirpsHandler)
+	apiRouter.Get("/search", apiCfg.SearchChirpsHandler)
+	apiRouter.Get("/trends", apiCfg.GetTrendsHandler)
+	apiRouter.Post("/media", apiCfg.UploadMediaHandler)
+	apiRouter.Get("/media/{mediaID}", apiCfg.GetMediaHandler)
+	apiRouter.Get("/media/{mediaID}/thumbnail", apiCfg.GetMediaThumbnailHandler)
+
+	adminRouter.Use(apiCfg.MiddlewareRequireRole(database.RoleModerator))
+	adminRouter.Use(apiCfg.MiddlewareAudit)
+	adminRouter.Group(func(adminOnly chi.Router) {
+		adminOnly.Use(apiCfg.MiddlewareRequireRole(database.RoleAdmin))
+		adminOnly.Get("/metrics", apiCfg.HandlerMetrics)
+		adminOnly.Get("/audit", apiCfg.GetAuditHandler)
+		adminOnly.Get("/users", apiCfg.GetUsersHandler)
+		adminOnly.Get("/users/{userID}", apiCfg.GetUserHandler)
+		adminOnly.Get("/users/{userID}/sessions", apiCfg.GetUserSessionsHandler)
+		adminOnly.Delete("/users/{userID}/sessions", apiCfg.RevokeUserSessionsHandler)
+		adminOnly.Post("/users/{userID}/password-reset", apiCfg.RequirePasswordResetHandler)
+		adminOnly.Put("/users/{userID}/chirpy-red", apiCfg.SetChirpyRedHandler)
+		adminOnly.Post("/users/{userID}/impersonate", apiCfg.ImpersonateUserHandler)
+		adminOnly.Put("/users/{userID}/role", apiCfg.SetUserRoleHandler)
+		adminOnly.Put("/users/{userID}/suspension", apiCfg.SuspendUserHandler)
+		adminOnly.Delete("/users/{userID}/suspension", apiCfg.LiftSuspensionHandler)
+	})
+	adminRouter.Patch("/chirps/{chirpID}/sensitivity", apiCfg.SetChirpSensitivityHandler)
+	adminRouter.Post("/chirps/{chirpID}/unhide", apiCfg.UnhideChirpHandler)
+	adminRouter.Get("/reports", apiCfg.GetReportsHandler)
+	adminRouter.Get("/reports/{reportID}", apiCfg.GetReportHandler)
+	adminRouter.Post("/reports/{reportID}/claim", apiCfg.ClaimReportHandler)
+	adminRouter.Post("/reports/{reportID}/resolve", apiCfg.ResolveReportHandler)
+	adminRouter.Get("/moderation-log", apiCfg.GetModerationLogHandler)
 
-	adminRouter.Get("/metrics", apiCfg.HandlerMetrics)
 	corsr := middleware.MiddlewareCors(r)
 
 	server := &http.Server{
@@ -71,3 +258,17 @@ func main() {
 	log.Printf("Serving on port : %s\n", port)
 	log.Fatal(server.ListenAndServe())
 }
+
+// positiveIntFromEnv reads a positive integer setting, falling back to
+// fallback when it isn't set.
+func positiveIntFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Fatalf("%s: must be a positive integer", name)
+	}
+	return n
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/AxterDoesCode/webserver/internal/audit"
+	"github.com/AxterDoesCode/webserver/internal/database"
+)
+
+// runCommand runs one of the maintenance commands given on the command line
+// instead of starting the server. They work on the database in the current
+// directory and should be run while the server is stopped.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "reindex":
+		db, err := database.NewDB(".")
+		if err != nil {
+			return err
+		}
+		return db.RebuildSearchIndex()
+	case "bootstrap-admin":
+		if len(args) != 2 {
+			return errors.New("Usage: bootstrap-admin <email>")
+		}
+		db, err := database.NewDB(".")
+		if err != nil {
+			return err
+		}
+		user, err := db.BootstrapAdmin(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("User %d (%s) is now an admin\n", user.ID, user.Email)
+		return nil
+	case "verify-audit":
+		count, err := audit.Verify(auditLogPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Audit log is intact: %d entries\n", count)
+		return nil
+	default:
+		return fmt.Errorf("Unknown command %q", args[0])
+	}
+}
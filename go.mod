@@ -6,5 +6,8 @@ require (
 	github.com/go-chi/chi/v5 v5.0.8
 	github.com/golang-jwt/jwt/v5 v5.0.0
 	github.com/joho/godotenv v1.5.1
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/crypto v0.10.0
+	golang.org/x/image v0.18.0
+	golang.org/x/text v0.16.0
 )
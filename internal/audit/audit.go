@@ -0,0 +1,410 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events recorded in the audit log. Filters match an event exactly or by the
+// part before the dot, so "login" matches both login events.
+const (
+	EventLoginSucceeded    = "login.succeeded"
+	EventLoginFailed       = "login.failed"
+	EventTokenRefreshed    = "token.refreshed"
+	EventTokenRevoked      = "token.revoked"
+	EventPasswordChanged   = "user.password_changed"
+	EventEmailChanged      = "user.email_changed"
+	EventChirpyRedUpgraded = "polka.upgraded"
+	EventChirpyRedRemoved  = "polka.downgraded"
+	EventChirpDeleted      = "chirp.deleted"
+	EventAdminAction       = "admin.action"
+	// EventImpersonationStarted is an admin starting to act as a user, and
+	// EventImpersonatedRequest each change made while doing so.
+	EventImpersonationStarted = "admin.impersonation_started"
+	EventImpersonatedRequest  = "impersonation.request"
+)
+
+// ErrTampered is returned by Verify when an entry doesn't match the chain.
+var ErrTampered = errors.New("Audit log has been tampered with")
+
+// Entry is one event. Hash covers every other field, including PrevHash,
+// the hash of the entry before it, so that changing, removing or reordering
+// entries breaks the chain from that point on. ImpersonatorID is set when
+// the actor was an admin impersonating ActorID.
+type Entry struct {
+	Seq            int               `json:"seq"`
+	At             time.Time         `json:"at"`
+	Event          string            `json:"event"`
+	ActorID        int               `json:"actor_id,omitempty"`
+	ImpersonatorID int               `json:"impersonator_id,omitempty"`
+	SubjectID      int               `json:"subject_id,omitempty"`
+	IP             string            `json:"ip,omitempty"`
+	Details        map[string]string `json:"details,omitempty"`
+	PrevHash       string            `json:"prev_hash"`
+	Hash           string            `json:"hash"`
+}
+
+// Filter selects entries for Query. Zero fields match everything. BeforeSeq
+// continues a listing after the entry with that sequence number.
+type Filter struct {
+	Event     string
+	ActorID   int
+	SubjectID int
+	Since     time.Time
+	Until     time.Time
+	BeforeSeq int
+	Limit     int
+}
+
+// Log is an append-only file of hash-chained entries, one JSON object per
+// line. It is safe for concurrent use.
+type Log struct {
+	path  string
+	mux   sync.Mutex
+	chain chainState
+}
+
+// chainState is kept next to the log, in path + ".chain". It records the
+// last entry Prune removed, which the oldest remaining entry must follow,
+// and the newest entry appended, which the log must still reach. Without
+// it, dropping entries from either end of the file would go unnoticed.
+type chainState struct {
+	PrunedSeq  int    `json:"pruned_seq"`
+	PrunedHash string `json:"pruned_hash"`
+	LastSeq    int    `json:"last_seq"`
+	LastHash   string `json:"last_hash"`
+}
+
+// Open opens the log at path, creating it when it doesn't exist. It fails
+// with an error wrapping ErrTampered rather than chain new entries onto a
+// log that doesn't verify.
+func Open(path string) (*Log, error) {
+	entries, chain, err := readLog(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	err = verifyEntries(entries, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{path: path, chain: chain}
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		l.chain.LastSeq = last.Seq
+		l.chain.LastHash = last.Hash
+	}
+	// Logs written before the chain state existed get it now.
+	err = l.writeChain(l.chain)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append chains entry onto the log and writes it out before returning it.
+// Seq, At and the hashes are filled in.
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	entry.Seq = l.chain.LastSeq + 1
+	entry.At = time.Now().UTC()
+	entry.PrevHash = l.chain.LastHash
+	entry.Hash = hashEntry(entry)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return Entry{}, err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		// Take the entry back out, so that the next one reuses its Seq
+		// without breaking the chain.
+		if truncErr := file.Truncate(info.Size()); truncErr != nil {
+			return Entry{}, errors.Join(err, truncErr)
+		}
+		return Entry{}, err
+	}
+
+	// The entry is in the log from here on, so the chain moves past it
+	// even if recording that fails. Open accepts a log that runs past the
+	// chain state.
+	l.chain.LastSeq = entry.Seq
+	l.chain.LastHash = entry.Hash
+	err = l.writeChain(l.chain)
+	if err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Query returns the entries matching filter, newest first, and whether more
+// follow.
+func (l *Log) Query(filter Filter) ([]Entry, bool, error) {
+	l.mux.Lock()
+	entries, err := readEntries(l.path)
+	l.mux.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]Entry, 0), false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	matched := make([]Entry, 0)
+	for i := len(entries) - 1; i >= 0 && len(matched) <= filter.Limit; i-- {
+		if filter.matches(entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+	hasMore := len(matched) > filter.Limit
+	if hasMore {
+		matched = matched[:filter.Limit]
+	}
+	return matched, hasMore, nil
+}
+
+// Prune removes the entries older than before, keeping the rest of the
+// chain intact. The last entry removed is recorded in the chain state, so
+// that the oldest entry left stays anchored to it.
+func (l *Log) Prune(before time.Time) (int, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	entries, err := readEntries(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	kept := 0
+	for kept < len(entries) && entries[kept].At.Before(before) {
+		kept++
+	}
+	if kept == 0 {
+		return 0, nil
+	}
+
+	// The chain state moves first: until the log is rewritten, Verify still
+	// finds the new anchor inside it.
+	chain := l.chain
+	chain.PrunedSeq = entries[kept-1].Seq
+	chain.PrunedHash = entries[kept-1].Hash
+	err = l.writeChain(chain)
+	if err != nil {
+		return 0, err
+	}
+	l.chain = chain
+
+	var builder strings.Builder
+	for _, entry := range entries[kept:] {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return 0, err
+		}
+		builder.Write(line)
+		builder.WriteByte('\n')
+	}
+	tmpPath := l.path + ".tmp"
+	err = os.WriteFile(tmpPath, []byte(builder.String()), 0600)
+	if err != nil {
+		return 0, err
+	}
+	return kept, os.Rename(tmpPath, l.path)
+}
+
+// RunRetention prunes entries older than retention every interval until stop
+// is closed. It prunes once straight away.
+func (l *Log) RunRetention(retention, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := l.Prune(time.Now().Add(-retention)); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Verify checks the chain of the log at path and returns how many entries
+// it holds. An error wrapping ErrTampered names the first entry that
+// doesn't fit.
+func Verify(path string) (int, error) {
+	entries, chain, err := readLog(path)
+	if err != nil {
+		return 0, err
+	}
+	err = verifyEntries(entries, chain)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func verifyEntries(entries []Entry, chain chainState) error {
+	for i, entry := range entries {
+		if entry.Hash != hashEntry(entry) {
+			return fmt.Errorf("%w: entry %d doesn't match its hash", ErrTampered, entry.Seq)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := entries[i-1]
+		if entry.Seq != prev.Seq+1 || entry.PrevHash != prev.Hash {
+			return fmt.Errorf("%w: entry %d doesn't follow entry %d", ErrTampered, entry.Seq, prev.Seq)
+		}
+	}
+
+	if len(entries) == 0 {
+		if chain.LastSeq > chain.PrunedSeq {
+			return fmt.Errorf("%w: entries after %d are missing", ErrTampered, chain.PrunedSeq)
+		}
+		return nil
+	}
+	first, last := entries[0], entries[len(entries)-1]
+	switch {
+	case first.Seq == chain.PrunedSeq+1:
+		if first.PrevHash != chain.PrunedHash {
+			return fmt.Errorf("%w: entry %d doesn't follow the pruned entries", ErrTampered, first.Seq)
+		}
+	case first.Seq <= chain.PrunedSeq && chain.PrunedSeq <= last.Seq:
+		// Prune stopped between recording the anchor and rewriting the log.
+		if entries[chain.PrunedSeq-first.Seq].Hash != chain.PrunedHash {
+			return fmt.Errorf("%w: entry %d doesn't match the pruned entry", ErrTampered, chain.PrunedSeq)
+		}
+	default:
+		return fmt.Errorf("%w: entries before %d are missing", ErrTampered, first.Seq)
+	}
+	// Append can stop between writing an entry and recording it, so the log
+	// may run past LastSeq, but it can't end before it.
+	if chain.LastSeq > last.Seq {
+		return fmt.Errorf("%w: entries after %d are missing", ErrTampered, last.Seq)
+	}
+	if chain.LastSeq >= first.Seq && entries[chain.LastSeq-first.Seq].Hash != chain.LastHash {
+		return fmt.Errorf("%w: entry %d doesn't match the last entry appended", ErrTampered, chain.LastSeq)
+	}
+	return nil
+}
+
+// writeChain replaces the chain state file.
+func (l *Log) writeChain(chain chainState) error {
+	data, err := json.Marshal(chain)
+	if err != nil {
+		return err
+	}
+	tmpPath := l.path + ".chain.tmp"
+	err = os.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, l.path+".chain")
+}
+
+// readLog reads the entries of the log at path and its chain state. A log
+// written before the chain state existed is anchored at its first entry
+// and can't be checked for entries missing at the end.
+func readLog(path string) ([]Entry, chainState, error) {
+	entries, err := readEntries(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, chainState{}, err
+	}
+	entriesErr := err
+
+	chain := chainState{}
+	data, err := os.ReadFile(path + ".chain")
+	switch {
+	case err == nil:
+		err = json.Unmarshal(data, &chain)
+		if err != nil {
+			return nil, chainState{}, fmt.Errorf("%s.chain: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if len(entries) > 0 {
+			chain.PrunedSeq = entries[0].Seq - 1
+			chain.PrunedHash = entries[0].PrevHash
+		}
+	default:
+		return nil, chainState{}, err
+	}
+	return entries, chain, entriesErr
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if f.Event != "" && entry.Event != f.Event && !strings.HasPrefix(entry.Event, f.Event+".") {
+		return false
+	}
+	if f.ActorID != 0 && entry.ActorID != f.ActorID {
+		return false
+	}
+	if f.SubjectID != 0 && entry.SubjectID != f.SubjectID {
+		return false
+	}
+	if !f.Since.IsZero() && entry.At.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.At.Before(f.Until) {
+		return false
+	}
+	return f.BeforeSeq == 0 || entry.Seq < f.BeforeSeq
+}
+
+func hashEntry(entry Entry) string {
+	entry.Hash = ""
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		// Entries only hold strings, numbers and times, which always marshal.
+		panic(err)
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+func readEntries(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
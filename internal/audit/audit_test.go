@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestLog(t *testing.T, count int) (*Log, string, []Entry) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	entries := make([]Entry, 0, count)
+	for i := 0; i < count; i++ {
+		entry, err := l.Append(Entry{Event: EventLoginSucceeded, ActorID: i + 1})
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return l, path, entries
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func writeLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	data := ""
+	for _, line := range lines {
+		data += line + "\n"
+	}
+	err := os.WriteFile(path, []byte(data), 0600)
+	if err != nil {
+		t.Fatalf("writing log: %v", err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+	}{
+		{"first entry removed", func(lines []string) []string { return lines[1:] }},
+		{"last entry removed", func(lines []string) []string { return lines[:len(lines)-1] }},
+		{"middle entry removed", func(lines []string) []string {
+			return append(append([]string{}, lines[:2]...), lines[3:]...)
+		}},
+		{"entries swapped", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}},
+		{"entry changed", func(lines []string) []string {
+			lines[2] = strings.Replace(lines[2], EventLoginSucceeded, EventLoginFailed, 1)
+			return lines
+		}},
+		{"every entry removed", func(lines []string) []string { return nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, path, _ := openTestLog(t, 5)
+			if count, err := Verify(path); err != nil || count != 5 {
+				t.Fatalf("Verify before tampering = %d, %v; want 5 entries", count, err)
+			}
+
+			writeLines(t, path, tt.tamper(readLines(t, path)))
+			if _, err := Verify(path); !errors.Is(err, ErrTampered) {
+				t.Errorf("Verify error = %v, want ErrTampered", err)
+			}
+			if _, err := Open(path); !errors.Is(err, ErrTampered) {
+				t.Errorf("Open error = %v, want ErrTampered", err)
+			}
+		})
+	}
+}
+
+func TestPruneKeepsChainVerifiable(t *testing.T) {
+	l, path, entries := openTestLog(t, 5)
+
+	pruned, err := l.Prune(entries[2].At)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if pruned != 2 {
+		t.Fatalf("Prune removed %d entries, want 2", pruned)
+	}
+	if count, err := Verify(path); err != nil || count != 3 {
+		t.Fatalf("Verify after Prune = %d, %v; want 3 entries", count, err)
+	}
+
+	// Removing the oldest entry left is caught even though the one before
+	// it was pruned.
+	lines := readLines(t, path)
+	writeLines(t, path, lines[1:])
+	if _, err := Verify(path); !errors.Is(err, ErrTampered) {
+		t.Errorf("Verify with the oldest entry removed = %v, want ErrTampered", err)
+	}
+	writeLines(t, path, lines)
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open after Prune: %v", err)
+	}
+	next, err := reopened.Append(Entry{Event: EventLoginFailed})
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if next.Seq != 6 || next.PrevHash != entries[4].Hash {
+		t.Errorf("appended entry %d after %q, want 6 after %q", next.Seq, next.PrevHash, entries[4].Hash)
+	}
+	if count, err := Verify(path); err != nil || count != 4 {
+		t.Errorf("Verify after Append = %d, %v; want 4 entries", count, err)
+	}
+}
+
+func TestPruneEverythingThenReopen(t *testing.T) {
+	l, path, entries := openTestLog(t, 3)
+	last := entries[len(entries)-1]
+
+	if _, err := l.Prune(last.At.Add(1)); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if count, err := Verify(path); err != nil || count != 0 {
+		t.Fatalf("Verify of an emptied log = %d, %v; want 0 entries", count, err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	next, err := reopened.Append(Entry{Event: EventLoginFailed})
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if next.Seq != last.Seq+1 || next.PrevHash != last.Hash {
+		t.Errorf("appended entry %d after %q, want %d after %q", next.Seq, next.PrevHash, last.Seq+1, last.Hash)
+	}
+	if _, err := Verify(path); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestVerifyToleratesInterruptedWrites(t *testing.T) {
+	t.Run("prune stopped before rewriting the log", func(t *testing.T) {
+		l, path, entries := openTestLog(t, 4)
+		lines := readLines(t, path)
+		if _, err := l.Prune(entries[2].At); err != nil {
+			t.Fatalf("Prune: %v", err)
+		}
+		writeLines(t, path, lines)
+		if count, err := Verify(path); err != nil || count != 4 {
+			t.Errorf("Verify = %d, %v; want 4 entries", count, err)
+		}
+	})
+
+	t.Run("append stopped before recording the entry", func(t *testing.T) {
+		_, path, _ := openTestLog(t, 3)
+		chain, err := os.ReadFile(path + ".chain")
+		if err != nil {
+			t.Fatalf("reading chain state: %v", err)
+		}
+		l, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if _, err := l.Append(Entry{Event: EventLoginFailed}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if err := os.WriteFile(path+".chain", chain, 0600); err != nil {
+			t.Fatalf("restoring chain state: %v", err)
+		}
+		if count, err := Verify(path); err != nil || count != 4 {
+			t.Errorf("Verify = %d, %v; want 4 entries", count, err)
+		}
+	})
+
+	t.Run("append failed to record the entry", func(t *testing.T) {
+		l, path, _ := openTestLog(t, 3)
+		// A directory in the way makes writing the chain state fail.
+		if err := os.Mkdir(path+".chain.tmp", 0700); err != nil {
+			t.Fatalf("blocking chain state: %v", err)
+		}
+		if _, err := l.Append(Entry{Event: EventLoginFailed}); err == nil {
+			t.Fatal("Append succeeded without writing the chain state")
+		}
+		if err := os.Remove(path + ".chain.tmp"); err != nil {
+			t.Fatalf("unblocking chain state: %v", err)
+		}
+		entry, err := l.Append(Entry{Event: EventLoginFailed})
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if entry.Seq != 5 {
+			t.Errorf("Seq = %d, want 5", entry.Seq)
+		}
+		if _, err := Open(path); err != nil {
+			t.Errorf("Open: %v", err)
+		}
+		if count, err := Verify(path); err != nil || count != 5 {
+			t.Errorf("Verify = %d, %v; want 5 entries", count, err)
+		}
+	})
+}
+
+func TestOpenAnchorsLogsWithoutChainState(t *testing.T) {
+	l, path, entries := openTestLog(t, 4)
+	if _, err := l.Prune(entries[2].At); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if err := os.Remove(path + ".chain"); err != nil {
+		t.Fatalf("removing chain state: %v", err)
+	}
+
+	if _, err := Verify(path); err != nil {
+		t.Fatalf("Verify without chain state: %v", err)
+	}
+	if _, err := Open(path); err != nil {
+		t.Fatalf("Open without chain state: %v", err)
+	}
+	// Open recorded the chain state, so entries can't go missing from the
+	// end from now on.
+	lines := readLines(t, path)
+	writeLines(t, path, lines[:len(lines)-1])
+	if _, err := Verify(path); !errors.Is(err, ErrTampered) {
+		t.Errorf("Verify with the last entry removed = %v, want ErrTampered", err)
+	}
+}
@@ -0,0 +1,19 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("Blob doesn't exist")
+
+// BlobStore keeps opaque blobs under string keys. Keys are made of letters,
+// digits, dots, dashes and underscores.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get opens the blob stored under key. It returns ErrNotFound if there
+	// is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
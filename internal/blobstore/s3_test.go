@@ -0,0 +1,211 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testRegion    = "us-east-1"
+	testBucket    = "chirpy"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// s3StandIn is a minimal S3-compatible server. It checks each request's
+// Signature Version 4 independently of the signer under test and keeps
+// objects in memory.
+type s3StandIn struct {
+	mux     sync.Mutex
+	objects map[string]object
+}
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+var authorizationPattern = regexp.MustCompile(
+	`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`,
+)
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := s.verify(r, body); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>"+err.Error()+"</Message></Error>")
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	key := r.URL.EscapedPath()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = object{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		obj, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *s3StandIn) verify(r *http.Request, body []byte) error {
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		return errors.New("malformed Authorization header")
+	}
+	accessKey, date, region, signedHeaders, signature := match[1], match[2], match[3], match[4], match[5]
+	if accessKey != testAccessKey || region != testRegion {
+		return errors.New("wrong credential scope")
+	}
+	payloadHash := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		return errors.New("payload hash doesn't match the body")
+	}
+
+	var canonicalHeaders strings.Builder
+	names := strings.Split(signedHeaders, ";")
+	for _, name := range names {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	if !strings.Contains(";"+signedHeaders+";", ";host;") {
+		return errors.New("host isn't signed")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		r.Header.Get("X-Amz-Date"),
+		date + "/" + region + "/s3/aws4_request",
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(key)), []byte(signature)) {
+		return errors.New("signature doesn't match")
+	}
+	return nil
+}
+
+func newTestS3Store(t *testing.T) (*S3Store, *s3StandIn) {
+	t.Helper()
+	standIn := &s3StandIn{objects: make(map[string]object)}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+	return NewS3Store(server.URL+"/", testRegion, testBucket, testAccessKey, testSecretKey), standIn
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	store, standIn := newTestS3Store(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		key         string
+		data        []byte
+		contentType string
+	}{
+		{"abc123.jpg", []byte("jpeg bytes"), "image/jpeg"},
+		{"abc123_thumb.png", []byte("png bytes"), "image/png"},
+		{"empty", []byte{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := store.Put(ctx, tt.key, tt.data, tt.contentType); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			stored, ok := standIn.objects["/"+testBucket+"/"+tt.key]
+			if !ok {
+				t.Fatalf("object wasn't stored under /%s/%s", testBucket, tt.key)
+			}
+			if stored.contentType != tt.contentType {
+				t.Errorf("stored Content-Type = %q, want %q", stored.contentType, tt.contentType)
+			}
+
+			body, err := store.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			data, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				t.Fatalf("reading blob: %v", err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("Get = %q, want %q", data, tt.data)
+			}
+
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := store.Get(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3StoreGetMissing(t *testing.T) {
+	store, _ := newTestS3Store(t)
+	if _, err := store.Get(context.Background(), "missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	store, _ := newTestS3Store(t)
+	store.SecretAccessKey = "wrong"
+
+	ctx := context.Background()
+	if err := store.Put(ctx, "a.png", []byte("png"), "image/png"); err == nil ||
+		!strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put error = %v, want SignatureDoesNotMatch", err)
+	}
+	if _, err := store.Get(ctx, "a.png"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get error = %v, want a failure other than ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "a.png"); err == nil {
+		t.Error("Delete succeeded, want an error")
+	}
+}
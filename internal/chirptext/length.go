@@ -0,0 +1,36 @@
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLLength is what every link counts for, however long it is, so that a
+// link costs the same whether or not it has been shortened.
+const URLLength = 23
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://\S+`)
+
+// Length is how long text counts as against the chirp length limit: the
+// number of user-perceived characters (grapheme clusters) once the text is
+// in NFC, with each link counted as URLLength. An emoji made of several code
+// points, such as a flag or a family, is one character.
+func Length(text string) int {
+	text = norm.NFC.String(text)
+	length := 0
+	last := 0
+	for _, match := range urlPattern.FindAllStringIndex(text, -1) {
+		end := match[0] + len(trimURL(text[match[0]:match[1]]))
+		length += uniseg.GraphemeClusterCount(text[last:match[0]]) + URLLength
+		last = end
+	}
+	return length + uniseg.GraphemeClusterCount(text[last:])
+}
+
+// trimURL drops punctuation that ends the sentence rather than the link.
+func trimURL(url string) string {
+	return strings.TrimRight(url, ".,:;!?\"')]")
+}
@@ -0,0 +1,39 @@
+package chirptext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"ascii", "hello", 5},
+		{"40 emoji", strings.Repeat("😀", 40), 40},
+		{"flag is one character", "🇳🇿", 1},
+		{"family is one character", "👨‍👩‍👧‍👦", 1},
+		{"skin tone modifier joins its emoji", "👍🏽", 1},
+		{"decomposed accent counts once", "cafe\u0301", 4},
+		{"precomposed accent", "caf\u00e9", 4},
+		{"Han characters", "日本語", 3},
+		{"short link", "http://a.co", URLLength},
+		{"long link", "https://example.com/" + strings.Repeat("a", 200), URLLength},
+		{"link in a sentence", "see https://example.com/page now", 4 + URLLength + 4},
+		{"trailing punctuation isn't part of the link", "go to https://example.com.", 6 + URLLength + 1},
+		{"link in parentheses", "(https://example.com)", 1 + URLLength + 1},
+		{"two links", "http://a.io http://b.io", 2*URLLength + 1},
+		{"scheme is case-insensitive", "HTTPS://EXAMPLE.COM", URLLength},
+		{"bare domain isn't a link", "example.com", 11},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Length(tt.text); got != tt.want {
+				t.Errorf("Length(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package contentfilter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is the JSON form of a pipeline, for example
+//
+//	{"rules": [
+//	  {"name": "profanity", "type": "words", "words_file": "profanity.txt", "action": "mask"},
+//	  {"name": "links", "type": "regex", "pattern": "(?i)https?://bit\\.ly/", "action": "reject",
+//	   "reason": "Shortened links aren't allowed"},
+//	  {"name": "spam", "type": "words", "words": ["free crypto"], "action": "flag"}
+//	]}
+//
+// Rules run in the order given. A words_file has one entry per line, with
+// blank lines and lines starting with # ignored, and is resolved relative to
+// the config file.
+type Config struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+type RuleConfig struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Words     []string `json:"words"`
+	WordsFile string   `json:"words_file"`
+	Pattern   string   `json:"pattern"`
+	Action    Action   `json:"action"`
+	Reason    string   `json:"reason"`
+}
+
+// DefaultPipeline is used when there is no config file. It masks the words
+// chirps have always had masked.
+func DefaultPipeline() Pipeline {
+	return Pipeline{
+		NewWordList("default", ActionMask, "", []string{"kerfuffle", "sharbert", "fornax"}),
+	}
+}
+
+// LoadPipeline builds the pipeline described by the config file at path. It
+// also returns every file the pipeline was built from.
+func LoadPipeline(path string) (Pipeline, []string, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := Config{}
+	err = json.Unmarshal(file, &config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	files := []string{path}
+	pipeline := make(Pipeline, 0, len(config.Rules))
+	for i, rc := range config.Rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		switch rc.Action {
+		case ActionMask, ActionReject, ActionFlag:
+		default:
+			return nil, nil, fmt.Errorf("%s: %s: unknown action %q", path, rc.Name, rc.Action)
+		}
+
+		switch rc.Type {
+		case "words":
+			words := rc.Words
+			if rc.WordsFile != "" {
+				listPath := rc.WordsFile
+				if !filepath.IsAbs(listPath) {
+					listPath = filepath.Join(filepath.Dir(path), listPath)
+				}
+				listed, err := readWordsFile(listPath)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: %w", rc.Name, err)
+				}
+				words = append(append([]string{}, words...), listed...)
+				files = append(files, listPath)
+			}
+			pipeline = append(pipeline, NewWordList(rc.Name, rc.Action, rc.Reason, words))
+		case "regex":
+			regexRule, err := NewRegexRule(rc.Name, rc.Action, rc.Reason, rc.Pattern)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %s: %w", path, rc.Name, err)
+			}
+			pipeline = append(pipeline, regexRule)
+		default:
+			return nil, nil, fmt.Errorf("%s: %s: unknown rule type %q", path, rc.Name, rc.Type)
+		}
+	}
+	return pipeline, files, nil
+}
+
+func readWordsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, scanner.Err()
+}
+
+// Reloader is a ContentFilter that rebuilds its pipeline whenever the config
+// file or one of its word lists changes. Until a config file exists it uses
+// DefaultPipeline, and a config that fails to load leaves the previous
+// pipeline in place.
+type Reloader struct {
+	path     string
+	mux      sync.RWMutex
+	pipeline Pipeline
+	modTimes map[string]time.Time
+}
+
+func NewReloader(path string) (*Reloader, error) {
+	r := &Reloader{path: path, pipeline: DefaultPipeline()}
+	_, err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) Filter(result *Result) {
+	r.mux.RLock()
+	pipeline := r.pipeline
+	r.mux.RUnlock()
+	pipeline.Filter(result)
+}
+
+// Reload rebuilds the pipeline if any of its files changed since the last
+// load, and reports whether it did. A failed load isn't retried until the
+// files change again. Reload must not be called concurrently with itself.
+func (r *Reloader) Reload() (bool, error) {
+	if r.modTimes != nil && !r.changed() {
+		return false, nil
+	}
+
+	pipeline, files, err := LoadPipeline(r.path)
+	if errors.Is(err, os.ErrNotExist) && r.modTimes == nil {
+		// Watch for the config file to appear.
+		r.modTimes = map[string]time.Time{r.path: {}}
+		return false, nil
+	}
+	if err != nil {
+		for file := range r.modTimes {
+			r.modTimes[file] = modTime(file)
+		}
+		return false, err
+	}
+
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		modTimes[file] = modTime(file)
+	}
+	r.mux.Lock()
+	r.pipeline = pipeline
+	r.modTimes = modTimes
+	r.mux.Unlock()
+	return true, nil
+}
+
+// Run checks for changes every interval until stop is closed.
+func (r *Reloader) Run(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if _, err := r.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	for file, loaded := range r.modTimes {
+		if !modTime(file).Equal(loaded) {
+			return true
+		}
+	}
+	return false
+}
+
+// modTime returns the zero time for files that can't be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
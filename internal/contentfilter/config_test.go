@@ -0,0 +1,125 @@
+package contentfilter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes contents to name in dir and gives it a modification time
+// later than any earlier write, so reloads notice the change even on file
+// systems with coarse clocks.
+func writeFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writes++
+	mtime := time.Now().Add(time.Duration(writes) * time.Hour)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var writes int
+
+func TestLoadPipeline(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "profanity.txt", "# masked words\nkerfuffle\n\n  sharbert  \n")
+	path := writeFile(t, dir, "filters.json", `{"rules": [
+		{"name": "profanity", "type": "words", "words": ["fornax"], "words_file": "profanity.txt", "action": "mask"},
+		{"name": "links", "type": "regex", "pattern": "(?i)bit\\.ly/", "action": "reject", "reason": "No short links"}
+	]}`)
+
+	pipeline, files, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatalf("LoadPipeline: %v", err)
+	}
+	wantFiles := []string{path, filepath.Join(dir, "profanity.txt")}
+	if strings.Join(files, ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("files = %v, want %v", files, wantFiles)
+	}
+
+	got := Apply(pipeline, "Fornax, kerfuffle and sharbert")
+	if got.Text != "****, **** and ****" {
+		t.Errorf("Apply masked %q", got.Text)
+	}
+	got = Apply(pipeline, "see bit.ly/x")
+	if !got.Rejected || got.Reason != "No short links" {
+		t.Errorf("Apply = %+v, want a rejection", got)
+	}
+}
+
+func TestLoadPipelineErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"invalid JSON", `{"rules": [`, "unexpected end"},
+		{"unknown action", `{"rules": [{"type": "words", "words": ["a"], "action": "shout"}]}`, `rule 1: unknown action "shout"`},
+		{"unknown type", `{"rules": [{"name": "x", "type": "glob", "action": "flag"}]}`, `x: unknown rule type "glob"`},
+		{"bad pattern", `{"rules": [{"name": "x", "type": "regex", "pattern": "(", "action": "flag"}]}`, "missing closing )"},
+		{"missing words file", `{"rules": [{"name": "x", "type": "words", "words_file": "nope.txt", "action": "mask"}]}`, "nope.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "filters.json", tt.config)
+			_, _, err := LoadPipeline(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadPipeline error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "filters.json")
+	filter := func(r *Reloader, text string) string {
+		return Apply(r, text).Text
+	}
+
+	r, err := NewReloader(configPath)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	if got := filter(r, "kerfuffle"); got != "****" {
+		t.Errorf("without a config the default pipeline should mask, got %q", got)
+	}
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Errorf("Reload with nothing changed = %v, %v", reloaded, err)
+	}
+
+	writeFile(t, dir, "words.txt", "zorp\n")
+	writeFile(t, dir, "filters.json", `{"rules": [{"type": "words", "words_file": "words.txt", "action": "mask"}]}`)
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload after the config appeared = %v, %v", reloaded, err)
+	}
+	if got := filter(r, "zorp kerfuffle"); got != "**** kerfuffle" {
+		t.Errorf("after loading the config got %q", got)
+	}
+
+	writeFile(t, dir, "words.txt", "zorp\nkerfuffle\n")
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload after the word list changed = %v, %v", reloaded, err)
+	}
+	if got := filter(r, "zorp kerfuffle"); got != "**** ****" {
+		t.Errorf("after changing the word list got %q", got)
+	}
+
+	writeFile(t, dir, "filters.json", `{"rules": [{"type": "words", "action": "shout"}]}`)
+	if reloaded, err := r.Reload(); reloaded || err == nil {
+		t.Fatalf("Reload of a bad config = %v, %v, want an error", reloaded, err)
+	}
+	if got := filter(r, "zorp kerfuffle"); got != "**** ****" {
+		t.Errorf("a bad config replaced the pipeline, got %q", got)
+	}
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Errorf("a bad config was retried before it changed: %v, %v", reloaded, err)
+	}
+}
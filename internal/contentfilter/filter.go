@@ -0,0 +1,106 @@
+package contentfilter
+
+import (
+	"sort"
+)
+
+// Action is what a rule does with the content it matches.
+type Action string
+
+const (
+	// ActionMask replaces each match with Mask.
+	ActionMask Action = "mask"
+	// ActionReject refuses the whole text.
+	ActionReject Action = "reject"
+	// ActionFlag lets the text through and records it for moderation.
+	ActionFlag Action = "flag"
+)
+
+const Mask = "****"
+
+const defaultRejectReason = "Chirp contains content that isn't allowed"
+
+// Flag records that a rule asked for the text to be reviewed.
+type Flag struct {
+	Rule  string `json:"rule"`
+	Match string `json:"match"`
+}
+
+// Result is the outcome of running text through a filter.
+type Result struct {
+	// Text is the text after masking.
+	Text     string
+	Rejected bool
+	// Reason explains a rejection to the user.
+	Reason string
+	Flags  []Flag
+}
+
+// ContentFilter inspects result.Text and masks it, rejects it or flags it.
+type ContentFilter interface {
+	Filter(result *Result)
+}
+
+// Apply runs text through filter.
+func Apply(filter ContentFilter, text string) Result {
+	result := Result{Text: text, Flags: make([]Flag, 0)}
+	filter.Filter(&result)
+	return result
+}
+
+// Pipeline runs filters in order, stopping at the first rejection.
+type Pipeline []ContentFilter
+
+func (p Pipeline) Filter(result *Result) {
+	for _, filter := range p {
+		filter.Filter(result)
+		if result.Rejected {
+			return
+		}
+	}
+}
+
+// span is a half-open byte range of result.Text.
+type span struct {
+	start, end int
+}
+
+// rule is the part shared by every kind of rule: a name to report it by and
+// what to do with its matches.
+type rule struct {
+	Name   string
+	Action Action
+	Reason string
+}
+
+func (r rule) apply(result *Result, spans []span) {
+	if len(spans) == 0 {
+		return
+	}
+
+	switch r.Action {
+	case ActionReject:
+		result.Rejected = true
+		result.Reason = r.Reason
+		if result.Reason == "" {
+			result.Reason = defaultRejectReason
+		}
+	case ActionFlag:
+		for _, s := range spans {
+			result.Flags = append(result.Flags, Flag{Rule: r.Name, Match: result.Text[s.start:s.end]})
+		}
+	case ActionMask:
+		// Replace from the end so earlier offsets stay valid.
+		sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+		text := result.Text
+		lastStart := len(text)
+		for _, s := range spans {
+			if s.end > lastStart {
+				continue
+			}
+			text = text[:s.start] + Mask + text[s.end:]
+			lastStart = s.start
+		}
+		result.Text = text
+	}
+}
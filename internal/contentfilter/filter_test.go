@@ -0,0 +1,70 @@
+package contentfilter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustRegexRule(t *testing.T, name string, action Action, reason, pattern string) *RegexRule {
+	t.Helper()
+	r, err := NewRegexRule(name, action, reason, pattern)
+	if err != nil {
+		t.Fatalf("NewRegexRule(%q): %v", pattern, err)
+	}
+	return r
+}
+
+func TestPipeline(t *testing.T) {
+	pipeline := Pipeline{
+		NewWordList("profanity", ActionMask, "", []string{"kerfuffle"}),
+		mustRegexRule(t, "spam", ActionFlag, "", `(?i)free \w+`),
+		mustRegexRule(t, "links", ActionReject, "No short links", `(?i)https?://bit\.ly/\S*`),
+		NewWordList("never", ActionFlag, "", []string{"unreachable"}),
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want Result
+	}{
+		{
+			name: "clean text passes",
+			text: "hello there",
+			want: Result{Text: "hello there", Flags: []Flag{}},
+		},
+		{
+			name: "masks before later rules see the text",
+			text: "free kerfuffle and FREE stuff",
+			want: Result{
+				Text:  "free **** and FREE stuff",
+				Flags: []Flag{{Rule: "spam", Match: "FREE stuff"}},
+			},
+		},
+		{
+			name: "rejection stops the pipeline",
+			text: "see http://bit.ly/x unreachable",
+			want: Result{
+				Text:     "see http://bit.ly/x unreachable",
+				Rejected: true,
+				Reason:   "No short links",
+				Flags:    []Flag{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(pipeline, tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultPipelineMasksPunctuatedWords(t *testing.T) {
+	got := Apply(DefaultPipeline(), "This is a kerfuffle! Sharbert, Fornax.")
+	want := "This is a ****! ****, ****."
+	if got.Text != want {
+		t.Errorf("Apply = %q, want %q", got.Text, want)
+	}
+}
@@ -0,0 +1,34 @@
+package contentfilter
+
+import (
+	"regexp"
+)
+
+// RegexRule matches a regular expression against the text as written.
+type RegexRule struct {
+	rule
+	pattern *regexp.Regexp
+}
+
+func NewRegexRule(name string, action Action, reason, pattern string) (*RegexRule, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexRule{
+		rule:    rule{Name: name, Action: action, Reason: reason},
+		pattern: compiled,
+	}, nil
+}
+
+func (r *RegexRule) Filter(result *Result) {
+	matches := r.pattern.FindAllStringIndex(result.Text, -1)
+	spans := make([]span, 0, len(matches))
+	for _, match := range matches {
+		if match[0] == match[1] {
+			continue
+		}
+		spans = append(spans, span{start: match[0], end: match[1]})
+	}
+	r.apply(result, spans)
+}
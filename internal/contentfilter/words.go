@@ -0,0 +1,105 @@
+package contentfilter
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// WordList matches whole words and phrases from a list. Both the list and
+// the text are normalized first, so case, accents, compatibility forms such
+// as full-width letters, and the punctuation around words don't matter:
+// "Kérfuffle!" matches the entry "kerfuffle".
+type WordList struct {
+	rule
+	// phrases holds the normalized entries, keyed by their first word.
+	phrases map[string][][]string
+}
+
+func NewWordList(name string, action Action, reason string, entries []string) *WordList {
+	list := &WordList{
+		rule:    rule{Name: name, Action: action, Reason: reason},
+		phrases: make(map[string][][]string),
+	}
+	for _, entry := range entries {
+		words := splitWords(entry)
+		if len(words) == 0 {
+			continue
+		}
+		phrase := make([]string, len(words))
+		for i, word := range words {
+			phrase[i] = normalizeWord(entry[word.start:word.end])
+		}
+		list.phrases[phrase[0]] = append(list.phrases[phrase[0]], phrase)
+	}
+	return list
+}
+
+func (l *WordList) Filter(result *Result) {
+	words := splitWords(result.Text)
+	normalized := make([]string, len(words))
+	for i, word := range words {
+		normalized[i] = normalizeWord(result.Text[word.start:word.end])
+	}
+
+	spans := make([]span, 0)
+	for i := 0; i < len(words); i++ {
+		for _, phrase := range l.phrases[normalized[i]] {
+			if matchesAt(normalized, i, phrase) {
+				last := i + len(phrase) - 1
+				spans = append(spans, span{start: words[i].start, end: words[last].end})
+				i = last
+				break
+			}
+		}
+	}
+	l.apply(result, spans)
+}
+
+func matchesAt(words []string, i int, phrase []string) bool {
+	if i+len(phrase) > len(words) {
+		return false
+	}
+	for j, word := range phrase {
+		if words[i+j] != word {
+			return false
+		}
+	}
+	return true
+}
+
+// splitWords finds the runs of letters, digits and combining marks in text.
+// Everything else, including punctuation, separates words.
+func splitWords(text string) []span {
+	words := make([]span, 0)
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)
+		switch {
+		case inWord && start == -1:
+			start = i
+		case !inWord && start != -1:
+			words = append(words, span{start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		words = append(words, span{start: start, end: len(text)})
+	}
+	return words
+}
+
+// normalizeWord applies compatibility decomposition, drops combining marks
+// and case-folds, so that visually equivalent spellings compare equal.
+func normalizeWord(word string) string {
+	decomposed := norm.NFKD.String(word)
+	var b strings.Builder
+	for _, r := range decomposed {
+		if !unicode.In(r, unicode.Mn, unicode.Me) {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(cases.Fold().String(b.String()))
+}
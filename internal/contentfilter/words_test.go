@@ -0,0 +1,62 @@
+package contentfilter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWordListNormalization(t *testing.T) {
+	list := NewWordList("test", ActionMask, "", []string{"kerfuffle", "Free Crypto", "straße"})
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain word", "what a kerfuffle", "what a ****"},
+		{"trailing punctuation", "what a kerfuffle!", "what a ****!"},
+		{"surrounding punctuation", "(kerfuffle)", "(****)"},
+		{"upper case", "KERFUFFLE", "****"},
+		{"accents", "k\u00e9rfuffle", "****"},
+		{"decomposed accents", "ke\u0301rfuffle", "****"},
+		{"full-width letters", "ｋｅｒｆｕｆｆｌｅ", "****"},
+		{"case folding", "STRASSE", "****"},
+		{"phrase across punctuation", "free, crypto!", "****!"},
+		{"phrase in other case", "FREE CRYPTO", "****"},
+		{"every occurrence", "kerfuffle kerfuffle", "**** ****"},
+		{"part of a longer word", "kerfuffled", "kerfuffled"},
+		{"half a phrase", "free stuff", "free stuff"},
+		{"nothing to mask", "hello", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(list, tt.text)
+			if got.Text != tt.want {
+				t.Errorf("Apply(%q).Text = %q, want %q", tt.text, got.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordListActions(t *testing.T) {
+	text := "Kerfuffle! again"
+
+	rejected := Apply(NewWordList("no", ActionReject, "Not here", []string{"kerfuffle"}), text)
+	if !rejected.Rejected || rejected.Reason != "Not here" {
+		t.Errorf("reject: Rejected = %v, Reason = %q", rejected.Rejected, rejected.Reason)
+	}
+
+	defaultReason := Apply(NewWordList("no", ActionReject, "", []string{"kerfuffle"}), text)
+	if defaultReason.Reason != defaultRejectReason {
+		t.Errorf("reject without a reason gave %q", defaultReason.Reason)
+	}
+
+	flagged := Apply(NewWordList("spam", ActionFlag, "", []string{"kerfuffle"}), text)
+	if flagged.Rejected || flagged.Text != text {
+		t.Errorf("flag changed the text to %q or rejected it", flagged.Text)
+	}
+	want := []Flag{{Rule: "spam", Match: "Kerfuffle"}}
+	if !reflect.DeepEqual(flagged.Flags, want) {
+		t.Errorf("Flags = %v, want %v", flagged.Flags, want)
+	}
+}
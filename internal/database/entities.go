@@ -0,0 +1,174 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	EntityHashtag = "hashtag"
+	EntityMention = "mention"
+)
+
+var (
+	ErrInvalidHandle = errors.New("Handles are 1-15 letters, digits or underscores")
+	ErrHandleTaken   = errors.New("Handle is already taken")
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+
+// Entity is a hashtag or mention found in a chirp body. Offsets are
+// half-open ranges over the body, in bytes and in runes, and cover the
+// leading # or @.
+type Entity struct {
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	UserID    int    `json:"user_id,omitempty"`
+	ByteStart int    `json:"byte_start"`
+	ByteEnd   int    `json:"byte_end"`
+	RuneStart int    `json:"rune_start"`
+	RuneEnd   int    `json:"rune_end"`
+}
+
+// NormalizeTag returns the form hashtags are indexed under.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// extractEntities finds the hashtags and mentions in body. A # or @ only
+// starts an entity at the beginning of the body or after a character that
+// can't be part of a word, so e-mail addresses and URL fragments are left
+// alone. Mentions of handles that don't belong to anyone are dropped.
+func (dbStruct DBStructure) extractEntities(body string) []Entity {
+	entities := make([]Entity, 0)
+	runeIndex := 0
+	var prev rune
+	for byteIndex := 0; byteIndex < len(body); {
+		r, size := utf8.DecodeRuneInString(body[byteIndex:])
+		if (r == '#' || r == '@') && (byteIndex == 0 || !isWordRune(prev)) {
+			entity, ok := dbStruct.entityAt(body, byteIndex, runeIndex)
+			if ok {
+				entities = append(entities, entity)
+				byteIndex = entity.ByteEnd
+				runeIndex = entity.RuneEnd
+				prev, _ = utf8.DecodeLastRuneInString(body[:byteIndex])
+				continue
+			}
+		}
+		prev = r
+		byteIndex += size
+		runeIndex++
+	}
+	return entities
+}
+
+func (dbStruct DBStructure) entityAt(body string, byteStart, runeStart int) (Entity, bool) {
+	sigil := body[byteStart]
+	end := byteStart + 1
+	runeEnd := runeStart + 1
+	for end < len(body) {
+		r, size := utf8.DecodeRuneInString(body[end:])
+		if sigil == '@' && !(r < utf8.RuneSelf && isWordRune(r)) {
+			break
+		}
+		if !isWordRune(r) {
+			break
+		}
+		end += size
+		runeEnd++
+	}
+	if end == byteStart+1 {
+		return Entity{}, false
+	}
+
+	entity := Entity{
+		Text:      body[byteStart+1 : end],
+		ByteStart: byteStart,
+		ByteEnd:   end,
+		RuneStart: runeStart,
+		RuneEnd:   runeEnd,
+	}
+	if sigil == '#' {
+		entity.Type = EntityHashtag
+		return entity, true
+	}
+
+	user, ok := dbStruct.userByHandle(entity.Text)
+	if !ok {
+		return Entity{}, false
+	}
+	entity.Type = EntityMention
+	entity.UserID = user.ID
+	return entity, true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+func (dbStruct DBStructure) userByHandle(handle string) (User, bool) {
+	if handle == "" {
+		return User{}, false
+	}
+	for _, user := range dbStruct.Users {
+		if strings.EqualFold(user.Handle, handle) {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+// checkHandle validates a handle for userID, which may be 0 for a user that
+// doesn't exist yet.
+func (dbStruct DBStructure) checkHandle(handle string, userID int) error {
+	if !handlePattern.MatchString(handle) {
+		return ErrInvalidHandle
+	}
+	if owner, ok := dbStruct.userByHandle(handle); ok && owner.ID != userID {
+		return ErrHandleTaken
+	}
+	return nil
+}
+
+// indexChirp extracts the chirp's entities and adds it to the hashtag and
+// mention indexes. It returns the chirp with its entities set.
+func (dbStruct *DBStructure) indexChirp(chirp Chirp) Chirp {
+	chirp.Entities = dbStruct.extractEntities(chirp.Body)
+	for _, entity := range chirp.Entities {
+		switch entity.Type {
+		case EntityHashtag:
+			tag := NormalizeTag(entity.Text)
+			if !containsID(dbStruct.TagIndex[tag], chirp.ID) {
+				dbStruct.TagIndex[tag] = append(dbStruct.TagIndex[tag], chirp.ID)
+			}
+		case EntityMention:
+			if !containsID(dbStruct.MentionIndex[entity.UserID], chirp.ID) {
+				dbStruct.MentionIndex[entity.UserID] = append(dbStruct.MentionIndex[entity.UserID], chirp.ID)
+			}
+		}
+	}
+	return chirp
+}
+
+// unindexChirp removes the chirp from the indexes its current entities put
+// it in.
+func (dbStruct *DBStructure) unindexChirp(chirp Chirp) {
+	for _, entity := range chirp.Entities {
+		switch entity.Type {
+		case EntityHashtag:
+			tag := NormalizeTag(entity.Text)
+			dbStruct.TagIndex[tag] = removeID(dbStruct.TagIndex[tag], chirp.ID)
+			if len(dbStruct.TagIndex[tag]) == 0 {
+				delete(dbStruct.TagIndex, tag)
+			}
+		case EntityMention:
+			dbStruct.MentionIndex[entity.UserID] = removeID(dbStruct.MentionIndex[entity.UserID], chirp.ID)
+			if len(dbStruct.MentionIndex[entity.UserID]) == 0 {
+				delete(dbStruct.MentionIndex, entity.UserID)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDB(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	return db
+}
+
+func addTestUser(t *testing.T, db *DB, name string) User {
+	t.Helper()
+	user, err := db.AddUser("password", fmt.Sprintf("%s@example.com", name), "")
+	if err != nil {
+		t.Fatalf("AddUser(%s): %v", name, err)
+	}
+	return user
+}
+
+func addTestChirp(t *testing.T, db *DB, chirp Chirp) Chirp {
+	t.Helper()
+	if chirp.Body == "" {
+		chirp.Body = "chirp"
+	}
+	created, err := db.CreateChirp(chirp)
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+	return created
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	ActivityPost    = "post"
+	ActivityReply   = "reply"
+	ActivityQuote   = "quote"
+	ActivityLike    = "like"
+	ActivityRechirp = "rechirp"
+)
+
+// Activity is something that happened to a chirp. For posts, Tags holds the
+// chirp's normalized hashtags; for the other kinds ChirpID is the chirp that
+// was replied to, quoted, liked or rechirped.
+type Activity struct {
+	Kind    string
+	ChirpID int
+	Tags    []string
+	At      time.Time
+}
+
+// ActivitySince returns the activity on public chirps at or after since,
+// ordered by time with ties broken by chirp ID and kind, so that callers
+// summing over it always get the same result.
+func (db *DB) ActivitySince(since time.Time) ([]Activity, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	activity := make([]Activity, 0)
+	add := func(kind string, chirpID int, at time.Time) {
+		target, ok := dbStruct.Chirps[chirpID]
+		if !ok || at.Before(since) || !dbStruct.chirpListedTo(0, target) {
+			return
+		}
+		entry := Activity{Kind: kind, ChirpID: chirpID, At: at}
+		if kind == ActivityPost {
+			entry.Tags = chirpTags(target)
+		}
+		activity = append(activity, entry)
+	}
+
+	for _, chirp := range dbStruct.Chirps {
+		// Replies and quotes only count when they are public themselves,
+		// not while they are pending, private or hidden.
+		if !dbStruct.chirpListedTo(0, chirp) {
+			continue
+		}
+		add(ActivityPost, chirp.ID, chirp.CreatedAt)
+		if chirp.InReplyTo != 0 {
+			add(ActivityReply, chirp.InReplyTo, chirp.CreatedAt)
+		}
+		if chirp.QuoteOf != 0 {
+			add(ActivityQuote, chirp.QuoteOf, chirp.CreatedAt)
+		}
+	}
+	for chirpID, engagements := range dbStruct.Likes {
+		for _, like := range engagements {
+			add(ActivityLike, chirpID, like.CreatedAt)
+		}
+	}
+	for chirpID, engagements := range dbStruct.Rechirps {
+		for _, rechirp := range engagements {
+			add(ActivityRechirp, chirpID, rechirp.CreatedAt)
+		}
+	}
+
+	sort.SliceStable(activity, func(i, j int) bool {
+		a, b := activity[i], activity[j]
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+		if a.ChirpID != b.ChirpID {
+			return a.ChirpID < b.ChirpID
+		}
+		return a.Kind < b.Kind
+	})
+	return activity, nil
+}
+
+// chirpTags returns the chirp's distinct normalized hashtags.
+func chirpTags(chirp Chirp) []string {
+	tags := make([]string, 0)
+	for _, entity := range chirp.Entities {
+		if entity.Type != EntityHashtag {
+			continue
+		}
+		tag := NormalizeTag(entity.Text)
+		found := false
+		for _, existing := range tags {
+			if existing == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
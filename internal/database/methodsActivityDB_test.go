@@ -0,0 +1,43 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActivitySinceCountsOnlyListedReplies(t *testing.T) {
+	db := newTestDB(t)
+	alice := addTestUser(t, db, "alice")
+	bob := addTestUser(t, db, "bob")
+
+	target := addTestChirp(t, db, Chirp{AuthorID: alice.ID})
+	addTestChirp(t, db, Chirp{AuthorID: bob.ID, InReplyTo: target.ID})
+	addTestChirp(t, db, Chirp{AuthorID: bob.ID, QuoteOf: target.ID})
+	for _, source := range []Chirp{
+		{AuthorID: bob.ID, InReplyTo: target.ID, Status: ChirpDraft},
+		{AuthorID: bob.ID, QuoteOf: target.ID, Visibility: VisibilityPrivate},
+		{AuthorID: bob.ID, InReplyTo: target.ID, Visibility: VisibilityUnlisted},
+		{AuthorID: bob.ID, QuoteOf: target.ID, Visibility: VisibilityFollowers},
+	} {
+		addTestChirp(t, db, source)
+	}
+	if _, err := db.LikeChirp(target.ID, bob.ID); err != nil {
+		t.Fatalf("LikeChirp: %v", err)
+	}
+
+	activity, err := db.ActivitySince(time.Time{})
+	if err != nil {
+		t.Fatalf("ActivitySince: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, entry := range activity {
+		if entry.ChirpID == target.ID {
+			counts[entry.Kind]++
+		}
+	}
+	want := map[string]int{ActivityPost: 1, ActivityReply: 1, ActivityQuote: 1, ActivityLike: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("activity on target = %v, want %v", counts, want)
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Tiers users can be listed by.
+const (
+	TierRegular = "regular"
+	TierRed     = "red"
+)
+
+var ErrInvalidTier = errors.New("Tier must be regular or red")
+
+// UserQuery describes a page of SearchUsers results. Email and Handle match
+// case-insensitively anywhere in the field; empty fields match every user.
+// AfterID is the ID of the last user of the previous page.
+type UserQuery struct {
+	Email   string
+	Handle  string
+	Tier    string
+	Role    string
+	AfterID int
+	Limit   int
+}
+
+// SearchUsers returns the users matching query in ID order, and whether more
+// follow.
+func (db *DB) SearchUsers(query UserQuery) ([]User, bool, error) {
+	if query.Tier != "" && query.Tier != TierRegular && query.Tier != TierRed {
+		return nil, false, ErrInvalidTier
+	}
+	if query.Role != "" && !ValidRole(query.Role) {
+		return nil, false, ErrInvalidRole
+	}
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	users := make([]User, 0)
+	for _, user := range dbStruct.Users {
+		if user.ID > query.AfterID && query.matches(user) {
+			users = append(users, user.withoutPassword())
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	hasMore := len(users) > query.Limit
+	if hasMore {
+		users = users[:query.Limit]
+	}
+	return users, hasMore, nil
+}
+
+func (query UserQuery) matches(user User) bool {
+	if query.Email != "" && !containsFold(user.Email, query.Email) {
+		return false
+	}
+	if query.Handle != "" && !containsFold(user.Handle, query.Handle) {
+		return false
+	}
+	if query.Tier != "" && user.ChirpyRed != (query.Tier == TierRed) {
+		return false
+	}
+	return query.Role == "" || user.Role == query.Role
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
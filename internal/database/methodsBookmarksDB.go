@@ -0,0 +1,86 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAlreadyBookmarked = errors.New("Chirp is already bookmarked")
+	ErrNotBookmarked     = errors.New("Chirp isn't bookmarked")
+)
+
+// BookmarkChirp saves a chirp the user can see to their bookmarks.
+func (db *DB) BookmarkChirp(chirpID, userID int) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		chirp, ok := dbStruct.Chirps[chirpID]
+		if !ok || !dbStruct.chirpVisibleTo(userID, chirp) {
+			return ErrChirpNotFound
+		}
+		for _, bookmark := range dbStruct.Bookmarks[userID] {
+			if bookmark.ChirpID == chirpID {
+				return ErrAlreadyBookmarked
+			}
+		}
+		dbStruct.Bookmarks[userID] = append(dbStruct.Bookmarks[userID], Bookmark{
+			ChirpID:   chirpID,
+			CreatedAt: time.Now().UTC(),
+		})
+		return nil
+	})
+}
+
+func (db *DB) UnbookmarkChirp(chirpID, userID int) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		bookmarks := removeBookmark(dbStruct.Bookmarks[userID], chirpID)
+		if len(bookmarks) == len(dbStruct.Bookmarks[userID]) {
+			return ErrNotBookmarked
+		}
+		if len(bookmarks) == 0 {
+			delete(dbStruct.Bookmarks, userID)
+		} else {
+			dbStruct.Bookmarks[userID] = bookmarks
+		}
+		return nil
+	})
+}
+
+// GetBookmarks returns a page of the user's bookmarked chirps, the most
+// recently bookmarked first, and the position to continue from if more
+// follow. Bookmarked chirps the user can no longer see are skipped.
+func (db *DB) GetBookmarks(userID int, after *Position, limit int) ([]Chirp, *Position, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chirps := make([]Chirp, 0)
+	var last *Position
+	bookmarks := dbStruct.Bookmarks[userID]
+	for i := len(bookmarks) - 1; i >= 0; i-- {
+		position := Position{CreatedAt: bookmarks[i].CreatedAt, ID: bookmarks[i].ChirpID}
+		if after != nil && !position.before(*after) {
+			continue
+		}
+		chirp, ok := dbStruct.Chirps[bookmarks[i].ChirpID]
+		if !ok || !dbStruct.chirpVisibleTo(userID, chirp) {
+			continue
+		}
+		if len(chirps) == limit {
+			return dbStruct.presentChirps(userID, chirps), last, nil
+		}
+		chirps = append(chirps, chirp)
+		last = &position
+	}
+	return dbStruct.presentChirps(userID, chirps), nil, nil
+}
+
+func removeBookmark(bookmarks []Bookmark, chirpID int) []Bookmark {
+	result := make([]Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		if bookmark.ChirpID != chirpID {
+			result = append(result, bookmark)
+		}
+	}
+	return result
+}
@@ -11,9 +11,23 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrUserNotFound   = errors.New("User doesn't exist")
+	ErrChirpNotFound  = errors.New("The chirp ID doesn't correspond to any Chirp")
+	ErrNotChirpAuthor = errors.New("Chirp does not belong to user")
+	ErrParentNotFound = errors.New("The chirp being replied to doesn't exist")
+	ErrQuotedNotFound = errors.New("The chirp being quoted doesn't exist")
+	ErrMediaNotFound  = errors.New("Media doesn't exist")
+	ErrTooManyMedia   = errors.New("A chirp can have at most 4 attachments")
+)
+
 func (db *DB) loadDB() (DBStructure, error) {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	return db.readFile()
+}
+
+func (db *DB) readFile() (DBStructure, error) {
 	var dbstructure DBStructure
 	file, err := os.ReadFile(db.path)
 	if err != nil {
@@ -22,205 +36,362 @@ func (db *DB) loadDB() (DBStructure, error) {
 
 	err = json.Unmarshal(file, &dbstructure)
 	if err != nil {
-		return dbstructure, nil
+		return dbstructure, err
 	}
+	dbstructure.ensureMaps()
 	return dbstructure, nil
 }
 
-func (db *DB) AddUser(password, email string) (User, error) {
-	dbStruct, err := db.loadDB()
+// update runs fn as a transaction: the database is read, changed by fn and
+// written back under a single lock, so concurrent updates can't overwrite
+// each other. Nothing is written if fn returns an error.
+func (db *DB) update(fn func(dbStruct *DBStructure) error) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStruct, err := db.readFile()
 	if err != nil {
-		return User{}, err
+		return err
 	}
-
-	_, exists, err := db.checkUserExists(email)
+	err = fn(&dbStruct)
 	if err != nil {
-		return User{}, errors.New("Error checking user exists")
-	}
-
-	if exists {
-		return User{}, errors.New("User already exists, please try a different email or login")
-	}
-
-	dbNextIndex := len(dbStruct.Users) + 1
-	returnUser := User{
-		ID:        dbNextIndex,
-		Email:     email,
-		ChirpyRed: false,
+		return err
 	}
+	return db.writeFile(dbStruct)
+}
 
-	fullUserDetails := returnUser
-
+// AddUser creates a user. handle is optional.
+func (db *DB) AddUser(password, email, handle string) (User, error) {
 	bytePass := []byte(password)
 	hash, err := bcrypt.GenerateFromPassword(bytePass, bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, err
 	}
-	fullUserDetails.Password = hash
 
-	dbStruct.Users[dbNextIndex] = fullUserDetails
-	err = db.writeDB(dbStruct)
+	var returnUser User
+	err = db.update(func(dbStruct *DBStructure) error {
+		if _, exists := dbStruct.userByEmail(email); exists {
+			return errors.New("User already exists, please try a different email or login")
+		}
+		if handle != "" {
+			if err := dbStruct.checkHandle(handle, 0); err != nil {
+				return err
+			}
+		}
+
+		dbNextIndex := len(dbStruct.Users) + 1
+		now := time.Now().UTC()
+		returnUser = User{
+			ID:        dbNextIndex,
+			Email:     email,
+			Handle:    handle,
+			ChirpyRed: false,
+			Role:      RoleUser,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		fullUserDetails := returnUser
+		fullUserDetails.Password = hash
+		dbStruct.Users[dbNextIndex] = fullUserDetails
+		return nil
+	})
 	if err != nil {
 		return User{}, err
 	}
 	return returnUser, nil
 }
 
-func (db *DB) CreateChirp(authorID int, body string) (Chirp, error) {
-	dbStruct, err := db.loadDB()
-	if err != nil {
-		return Chirp{}, err
-	}
+// CreateChirp stores a new chirp. The caller sets the author, body, the
+// chirps being replied to and quoted, the visibility, the content warning and
+// sensitive flag, the attached media and the poll, if any; the store assigns
+// everything else. Chirps are public
+// unless another visibility is given. Only the author's own uploads can be
+// attached, and polls should come from NewPoll. A chirp with Status
+// ChirpDraft, or ChirpScheduled and a PublishAt, is saved without being
+// published.
+func (db *DB) CreateChirp(newChirp Chirp) (Chirp, error) {
+	visibility := newChirp.Visibility
+	if visibility == "" {
+		visibility = VisibilityPublic
+	}
+	if !ValidVisibility(visibility) {
+		return Chirp{}, ErrInvalidVisibility
+	}
+
+	var returnChirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		now := time.Now().UTC()
+		id := dbStruct.LastChirpID + 1
+		returnChirp = Chirp{
+			ID:             id,
+			Body:           newChirp.Body,
+			AuthorID:       newChirp.AuthorID,
+			ThreadRootID:   id,
+			ContentWarning: newChirp.ContentWarning,
+			Sensitive:      newChirp.Sensitive,
+			Visibility:     visibility,
+			Status:         ChirpPublished,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		switch {
+		case newChirp.Status == ChirpDraft:
+			returnChirp.Status = ChirpDraft
+		case newChirp.Status == ChirpScheduled && newChirp.PublishAt != nil:
+			publishAt := newChirp.PublishAt.UTC()
+			returnChirp.Status = ChirpScheduled
+			returnChirp.PublishAt = &publishAt
+		}
 
-	dbNextIndex := len(dbStruct.Chirps) + 1
-	returnChirp := Chirp{
-		ID:       dbNextIndex,
-		Body:     body,
-		AuthorID: authorID,
-	}
+		if newChirp.InReplyTo != 0 {
+			parent, ok := dbStruct.Chirps[newChirp.InReplyTo]
+			if !ok || !dbStruct.chirpVisibleTo(newChirp.AuthorID, parent) {
+				return ErrParentNotFound
+			}
+			returnChirp.InReplyTo = parent.ID
+			returnChirp.ThreadRootID = parent.ThreadRootID
+		}
+
+		if newChirp.QuoteOf != 0 {
+			quoted, ok := dbStruct.Chirps[newChirp.QuoteOf]
+			if !ok || !dbStruct.chirpVisibleTo(newChirp.AuthorID, quoted) {
+				return ErrQuotedNotFound
+			}
+			returnChirp.QuoteOf = quoted.ID
+		}
+
+		if len(newChirp.MediaIDs) > maxChirpMedia {
+			return ErrTooManyMedia
+		}
+		for _, mediaID := range newChirp.MediaIDs {
+			media, ok := dbStruct.Media[mediaID]
+			if !ok || media.OwnerID != newChirp.AuthorID {
+				return ErrMediaNotFound
+			}
+			if !containsString(returnChirp.MediaIDs, mediaID) {
+				returnChirp.MediaIDs = append(returnChirp.MediaIDs, mediaID)
+			}
+		}
 
-	dbStruct.Chirps[dbNextIndex] = returnChirp
-	err = db.writeDB(dbStruct)
+		if newChirp.Poll != nil {
+			returnChirp.Poll = &Poll{
+				Options:         append([]string{}, newChirp.Poll.Options...),
+				DurationMinutes: newChirp.Poll.DurationMinutes,
+				Counts:          make([]int, len(newChirp.Poll.Options)),
+			}
+		}
+
+		dbStruct.LastChirpID = id
+		if returnChirp.Published() {
+			dbStruct.countReferences(returnChirp, 1)
+			returnChirp.startPoll(now)
+		}
+		returnChirp = dbStruct.indexChirp(returnChirp)
+		dbStruct.Chirps[id] = returnChirp
+		returnChirp = dbStruct.presentChirp(newChirp.AuthorID, returnChirp)
+		return nil
+	})
 	if err != nil {
 		return Chirp{}, err
 	}
+	db.indexForSearch(returnChirp)
 	return returnChirp, nil
 }
 
+// countReferences adds delta to the reply and quote counts of the chirps a
+// published chirp replies to and quotes.
+func (dbStruct *DBStructure) countReferences(chirp Chirp, delta int) {
+	if parent, ok := dbStruct.Chirps[chirp.InReplyTo]; ok {
+		parent.ReplyCount += delta
+		dbStruct.Chirps[parent.ID] = parent
+	}
+	if quoted, ok := dbStruct.Chirps[chirp.QuoteOf]; ok {
+		quoted.QuoteCount += delta
+		dbStruct.Chirps[quoted.ID] = quoted
+	}
+}
+
 func (db *DB) checkUserExists(email string) (User, bool, error) {
 	dbStruct, err := db.loadDB()
 	if err != nil {
 		return User{}, false, errors.New("failed to load DB")
 	}
+	user, exists := dbStruct.userByEmail(email)
+	return user, exists, nil
+}
+
+func (dbStruct DBStructure) userByEmail(email string) (User, bool) {
 	for _, val := range dbStruct.Users {
 		if val.Email == email {
-			return val, true, nil
+			return val, true
 		}
 	}
-	return User{}, false, nil
+	return User{}, false
 }
 
-func (db *DB) GetChirpByID(id int) (Chirp, error) {
+func (db *DB) GetChirpByID(id, viewerID int) (Chirp, error) {
 	dbStruct, err := db.loadDB()
 	if err != nil {
 		return Chirp{}, err
 	}
 	elem, ok := dbStruct.Chirps[id]
-	if !ok {
-		return Chirp{}, errors.New("The chirp ID doesn't correspond to any Chirp")
+	if !ok || !dbStruct.chirpVisibleTo(viewerID, elem) {
+		return Chirp{}, ErrChirpNotFound
 	}
-	return elem, nil
+	return dbStruct.presentChirp(viewerID, elem), nil
 }
 
-func (db *DB) DeleteChirpByID(id int) (Chirp, error) {
+// GetChirpsByIDs returns the chirps with the given IDs that viewerID can
+// see, in the order given. Missing chirps are left out.
+func (db *DB) GetChirpsByIDs(ids []int, viewerID int) ([]Chirp, error) {
 	dbStruct, err := db.loadDB()
 	if err != nil {
-		return Chirp{}, err
-	}
-	chirpToBeRemoved, ok := dbStruct.Chirps[id]
-	if !ok {
-		return Chirp{}, errors.New("The chirp ID doesn't correspond to any Chirp")
-	}
-	delete(dbStruct.Chirps, id)
-	err = db.writeDB(dbStruct)
-	if err != nil {
-		return Chirp{}, err
-	}
-	return chirpToBeRemoved, nil
-}
-
-func (db *DB) GetChirpsArr() ([]Chirp, error) {
-	dat, err := db.loadDB()
-	chirpSlice := make([]Chirp, 0)
-	if err != nil {
-		return chirpSlice, err
+		return nil, err
 	}
-
-	for _, val := range dat.Chirps {
-		chirpSlice = append(chirpSlice, val)
+	chirps := make([]Chirp, 0, len(ids))
+	for _, id := range ids {
+		elem, ok := dbStruct.Chirps[id]
+		if ok && dbStruct.chirpVisibleTo(viewerID, elem) {
+			chirps = append(chirps, elem)
+		}
 	}
-	return chirpSlice, nil
+	return dbStruct.presentChirps(viewerID, chirps), nil
 }
 
-func (db *DB) GetChirpsByAuthor(authorID int) ([]Chirp, error) {
-	dat, err := db.loadDB()
-	chirpSlice := make([]Chirp, 0)
-	if err != nil {
-		return chirpSlice, err
-	}
+// DeleteChirpByID removes one of authorID's chirps. Replies to it are kept
+// and still point at it through InReplyTo, so threads show the gap. Chirps
+// hidden by moderators can't be deleted, so that they are still there if
+// the author appeals.
+func (db *DB) DeleteChirpByID(id, authorID int) (Chirp, error) {
+	var chirpToBeRemoved Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		chirpToBeRemoved, ok = dbStruct.Chirps[id]
+		if !ok {
+			return ErrChirpNotFound
+		}
+		if chirpToBeRemoved.AuthorID != authorID {
+			return ErrNotChirpAuthor
+		}
+		if chirpToBeRemoved.Hidden {
+			return ErrChirpHidden
+		}
 
-	for _, val := range dat.Chirps {
-		if val.AuthorID == authorID {
-			chirpSlice = append(chirpSlice, val)
+		if chirpToBeRemoved.Published() {
+			dbStruct.countReferences(chirpToBeRemoved, -1)
+		}
+		dbStruct.unindexChirp(chirpToBeRemoved)
+		delete(dbStruct.Chirps, id)
+		if chirpToBeRemoved.InReplyTo != 0 && dbStruct.hasReplies(id) {
+			dbStruct.DeletedReplies[id] = DeletedReply{
+				InReplyTo:    chirpToBeRemoved.InReplyTo,
+				ThreadRootID: chirpToBeRemoved.ThreadRootID,
+				CreatedAt:    chirpToBeRemoved.CreatedAt,
+			}
+		}
+		delete(dbStruct.Revisions, id)
+		delete(dbStruct.Likes, id)
+		delete(dbStruct.Rechirps, id)
+		delete(dbStruct.ContentFlags, id)
+		delete(dbStruct.PollVotes, id)
+		dbStruct.unpin(authorID, id)
+		for userID, bookmarks := range dbStruct.Bookmarks {
+			dbStruct.Bookmarks[userID] = removeBookmark(bookmarks, id)
+			if len(dbStruct.Bookmarks[userID]) == 0 {
+				delete(dbStruct.Bookmarks, userID)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
 	}
-	return chirpSlice, nil
+	db.unindexForSearch(id)
+	return chirpToBeRemoved, nil
 }
 
+// NewDB opens the database in path, creating it if it doesn't exist yet and
+// migrating it to the current schema otherwise.
 func NewDB(path string) (*DB, error) {
-	_, err := os.Create(path + "/database.json")
-	if err != nil {
-		return nil, err
-	}
-
 	returnDB := DB{
-		path: path + "/database.json",
-		mux:  &sync.RWMutex{},
+		path:       path + "/database.json",
+		mux:        &sync.RWMutex{},
+		searchPath: path + "/search_index.json",
 	}
 
-	var dbstructure DBStructure
-	dbstructure.Chirps = make(map[int]Chirp)
-	dbstructure.Users = make(map[int]User)
-	dbstructure.RevokedTokens = make(map[string]RevokedToken)
+	dbstructure, err := returnDB.loadDB()
+	if errors.Is(err, os.ErrNotExist) {
+		dbstructure = DBStructure{SchemaVersion: len(migrations)}
+		dbstructure.ensureMaps()
+	} else if err != nil {
+		return nil, err
+	}
 
-	dat, err := json.Marshal(dbstructure)
+	migrateDB(&dbstructure, time.Now().UTC())
+	err = returnDB.writeDB(dbstructure)
 	if err != nil {
 		return nil, err
 	}
-
-	err = os.WriteFile(returnDB.path, dat, 0666)
+	err = returnDB.openSearchIndex()
 	if err != nil {
 		return nil, err
 	}
 	return &returnDB, nil
 }
 
-func (db *DB) UpdateUser(idStr, email, newPassword string) (User, error) {
-	dbStruct, err := db.loadDB()
+// UpdateUser replaces a user's email and password. The handle is only
+// changed when a new one is given.
+func (db *DB) UpdateUser(idStr, email, newPassword, handle string) (User, error) {
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return User{}, err
 	}
 
-	id, err := strconv.Atoi(idStr)
+	bytePass := []byte(newPassword)
+	hash, err := bcrypt.GenerateFromPassword(bytePass, bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, err
 	}
 
-	elem, ok := dbStruct.Users[id]
-	if !ok {
-		return User{}, errors.New("ID cannot be found in databse")
-	}
+	var elem User
+	err = db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		elem, ok = dbStruct.Users[id]
+		if !ok {
+			return errors.New("ID cannot be found in databse")
+		}
+		if handle != "" {
+			if err := dbStruct.checkHandle(handle, id); err != nil {
+				return err
+			}
+			elem.Handle = handle
+		}
 
-	bytePass := []byte(newPassword)
-	hash, err := bcrypt.GenerateFromPassword(bytePass, bcrypt.DefaultCost)
+		elem.Email = email
+		elem.Password = hash
+		elem.PasswordResetRequired = false
+		elem.UpdatedAt = time.Now().UTC()
+		dbStruct.Users[id] = elem
+		return nil
+	})
 	if err != nil {
 		return User{}, err
 	}
-	elem.Email = email
-	elem.Password = hash
-	dbStruct.Users[id] = elem
-	err = db.writeDB(dbStruct)
+
+	return elem.withoutPassword(), nil
+}
+
+func (db *DB) GetUser(id int) (User, error) {
+	dbStruct, err := db.loadDB()
 	if err != nil {
 		return User{}, err
 	}
 
-	returnUser := User{
-		ID:        elem.ID,
-		Email:     elem.Email,
-		ChirpyRed: elem.ChirpyRed,
+	user, ok := dbStruct.Users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
 	}
-
-	return returnUser, nil
+	return user.withoutPassword(), nil
 }
 
 func (db *DB) ValidateLogin(email, password string) (User, error) {
@@ -239,25 +410,28 @@ func (db *DB) ValidateLogin(email, password string) (User, error) {
 		return User{}, err
 	}
 
-	return User{
-		ID:        matchedUser.ID,
-		Email:     matchedUser.Email,
-		ChirpyRed: matchedUser.ChirpyRed,
-	}, nil
+	return matchedUser.withoutPassword(), nil
 }
 
 func (db *DB) writeDB(dbStructure DBStructure) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
+	return db.writeFile(dbStructure)
+}
+
+// writeFile replaces the database file through a rename so that a crash
+// mid-write never leaves a truncated database behind.
+func (db *DB) writeFile(dbStructure DBStructure) error {
 	marshalData, err := json.Marshal(dbStructure)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(db.path, marshalData, 0666)
+	tmpPath := db.path + ".tmp"
+	err = os.WriteFile(tmpPath, marshalData, 0666)
 	if err != nil {
 		return err
 	}
-	return nil
+	return os.Rename(tmpPath, db.path)
 }
 
 func (db *DB) CheckRefreshTokenRevoked(refreshToken string) error {
@@ -274,40 +448,48 @@ func (db *DB) CheckRefreshTokenRevoked(refreshToken string) error {
 }
 
 func (db *DB) RevokeToken(refreshToken string, revokeTime time.Time) (RevokedToken, error) {
-	dbStruct, err := db.loadDB()
-	if err != nil {
-		return RevokedToken{}, err
-	}
-
 	newRevokedToken := RevokedToken{
 		ID:         refreshToken,
 		RevokeTime: revokeTime,
 	}
 
-	dbStruct.RevokedTokens[refreshToken] = newRevokedToken
-	err = db.writeDB(dbStruct)
+	err := db.update(func(dbStruct *DBStructure) error {
+		dbStruct.RevokedTokens[refreshToken] = newRevokedToken
+		return nil
+	})
 	if err != nil {
 		return RevokedToken{}, err
 	}
 	return newRevokedToken, nil
 }
 
-func (db *DB) UpgradeUser(userID int) error {
-	dbStruct, err := db.loadDB()
-	if err != nil {
-		return err
-	}
+// DowngradeUser takes a user off Chirpy Red, unpinning their oldest pins
+// until they are within pinLimit, the limit for regular users.
+func (db *DB) DowngradeUser(userID, pinLimit int) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		elem, exists := dbStruct.Users[userID]
+		if !exists {
+			return ErrUserNotFound
+		}
 
-	elem, exists := dbStruct.Users[userID]
-	if !exists {
-		return errors.New("User cannot be upgraded as user doesn't exist")
-	}
+		elem.ChirpyRed = false
+		elem.UpdatedAt = time.Now().UTC()
+		dbStruct.Users[userID] = elem
+		dbStruct.trimPins(userID, pinLimit)
+		return nil
+	})
+}
 
-	elem.ChirpyRed = true
-	dbStruct.Users[userID] = elem
-	err = db.writeDB(dbStruct)
-	if err != nil {
-		return err
-	}
-	return nil
+func (db *DB) UpgradeUser(userID int) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		elem, exists := dbStruct.Users[userID]
+		if !exists {
+			return errors.New("User cannot be upgraded as user doesn't exist")
+		}
+
+		elem.ChirpyRed = true
+		elem.UpdatedAt = time.Now().UTC()
+		dbStruct.Users[userID] = elem
+		return nil
+	})
 }
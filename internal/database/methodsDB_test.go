@@ -0,0 +1,43 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetChirpsByIDs(t *testing.T) {
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	viewer := addTestUser(t, db, "viewer")
+	first := addTestChirp(t, db, Chirp{AuthorID: author.ID})
+	private := addTestChirp(t, db, Chirp{AuthorID: author.ID, Visibility: VisibilityPrivate})
+	last := addTestChirp(t, db, Chirp{AuthorID: author.ID})
+
+	tests := []struct {
+		name     string
+		viewerID int
+		ids      []int
+		want     []int
+	}{
+		{"keeps the order given", viewer.ID, []int{last.ID, first.ID}, []int{last.ID, first.ID}},
+		{"leaves out chirps the viewer can't see", viewer.ID, []int{private.ID, first.ID}, []int{first.ID}},
+		{"authors see their own chirps", author.ID, []int{private.ID}, []int{private.ID}},
+		{"leaves out missing chirps", viewer.ID, []int{first.ID, 999}, []int{first.ID}},
+		{"no IDs", viewer.ID, nil, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chirps, err := db.GetChirpsByIDs(tt.ids, tt.viewerID)
+			if err != nil {
+				t.Fatalf("GetChirpsByIDs: %v", err)
+			}
+			got := make([]int, 0, len(chirps))
+			for _, chirp := range chirps {
+				got = append(got, chirp.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetChirpsByIDs(%v) = %v, want %v", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAlreadyEngaged = errors.New("User has already done this to the chirp")
+	ErrNotEngaged     = errors.New("User hasn't done this to the chirp")
+	ErrNotRechirpable = errors.New("Only public and unlisted chirps can be rechirped")
+)
+
+func (db *DB) LikeChirp(chirpID, userID int) (Chirp, error) {
+	return db.engage(chirpID, userID, likes)
+}
+
+func (db *DB) UnlikeChirp(chirpID, userID int) (Chirp, error) {
+	return db.disengage(chirpID, userID, likes)
+}
+
+func (db *DB) Rechirp(chirpID, userID int) (Chirp, error) {
+	return db.engage(chirpID, userID, rechirps)
+}
+
+func (db *DB) Unrechirp(chirpID, userID int) (Chirp, error) {
+	return db.disengage(chirpID, userID, rechirps)
+}
+
+// GetLikers returns a page of the users who liked a chirp, in the order they
+// liked it, and the position to continue from if more follow.
+func (db *DB) GetLikers(chirpID, viewerID int, after *Position, limit int) ([]User, *Position, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chirp, ok := dbStruct.Chirps[chirpID]
+	if !ok || !dbStruct.chirpVisibleTo(viewerID, chirp) {
+		return nil, nil, ErrChirpNotFound
+	}
+
+	users := make([]User, 0)
+	var last *Position
+	for _, like := range dbStruct.Likes[chirpID] {
+		position := Position{CreatedAt: like.CreatedAt, ID: like.UserID}
+		if after != nil && !after.before(position) {
+			continue
+		}
+		if len(users) == limit {
+			return users, last, nil
+		}
+		user, ok := dbStruct.Users[like.UserID]
+		if !ok {
+			continue
+		}
+		users = append(users, user.withoutPassword())
+		last = &position
+	}
+	return users, nil, nil
+}
+
+// engagementKind picks the engagement list and counter a change applies to.
+type engagementKind struct {
+	list    func(dbStruct *DBStructure) map[int][]Engagement
+	counter func(chirp *Chirp) *int
+	// allows reports whether the chirp can be engaged with at all.
+	allows func(chirp Chirp) error
+}
+
+var (
+	likes = engagementKind{
+		list:    func(dbStruct *DBStructure) map[int][]Engagement { return dbStruct.Likes },
+		counter: func(chirp *Chirp) *int { return &chirp.LikeCount },
+	}
+	rechirps = engagementKind{
+		list:    func(dbStruct *DBStructure) map[int][]Engagement { return dbStruct.Rechirps },
+		counter: func(chirp *Chirp) *int { return &chirp.RechirpCount },
+		// Rechirps show up in the rechirper's feed, so they would carry
+		// chirps past the audience their author chose.
+		allows: func(chirp Chirp) error {
+			if chirp.Visibility != VisibilityPublic && chirp.Visibility != VisibilityUnlisted {
+				return ErrNotRechirpable
+			}
+			return nil
+		},
+	}
+)
+
+func (db *DB) engage(chirpID, userID int, kind engagementKind) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStruct.Chirps[chirpID]
+		if !ok || !dbStruct.chirpVisibleTo(userID, chirp) {
+			return ErrChirpNotFound
+		}
+		if kind.allows != nil {
+			if err := kind.allows(chirp); err != nil {
+				return err
+			}
+		}
+
+		engagements := kind.list(dbStruct)
+		if engagedIndex(engagements[chirpID], userID) != -1 {
+			return ErrAlreadyEngaged
+		}
+		engagements[chirpID] = append(engagements[chirpID], Engagement{
+			UserID:    userID,
+			CreatedAt: time.Now().UTC(),
+		})
+		*kind.counter(&chirp) = len(engagements[chirpID])
+		dbStruct.Chirps[chirpID] = chirp
+		chirp = dbStruct.presentChirp(userID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+func (db *DB) disengage(chirpID, userID int, kind engagementKind) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStruct.Chirps[chirpID]
+		if !ok || !dbStruct.chirpVisibleTo(userID, chirp) {
+			return ErrChirpNotFound
+		}
+
+		engagements := kind.list(dbStruct)
+		i := engagedIndex(engagements[chirpID], userID)
+		if i == -1 {
+			return ErrNotEngaged
+		}
+		engagements[chirpID] = append(engagements[chirpID][:i], engagements[chirpID][i+1:]...)
+		if len(engagements[chirpID]) == 0 {
+			delete(engagements, chirpID)
+		}
+		*kind.counter(&chirp) = len(engagements[chirpID])
+		dbStruct.Chirps[chirpID] = chirp
+		chirp = dbStruct.presentChirp(userID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+func engagedIndex(engagements []Engagement, userID int) int {
+	for i, engagement := range engagements {
+		if engagement.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// rechirperAmong returns the first of userIDs to have rechirped chirpID,
+// or 0 if none of them did.
+func (dbStruct DBStructure) rechirperAmong(chirpID int, userIDs []int) int {
+	for _, rechirp := range dbStruct.Rechirps[chirpID] {
+		if containsID(userIDs, rechirp.UserID) {
+			return rechirp.UserID
+		}
+	}
+	return 0
+}
+
+func (p Position) before(other Position) bool {
+	if !p.CreatedAt.Equal(other.CreatedAt) {
+		return p.CreatedAt.Before(other.CreatedAt)
+	}
+	return p.ID < other.ID
+}
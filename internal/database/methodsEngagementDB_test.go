@@ -0,0 +1,78 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEngagementCountersUnderConcurrency(t *testing.T) {
+	const users = 16
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	chirp := addTestChirp(t, db, Chirp{AuthorID: author.ID})
+	engagers := make([]User, users)
+	for i := range engagers {
+		engagers[i] = addTestUser(t, db, fmt.Sprintf("user%d", i))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*users)
+	for i, user := range engagers {
+		wg.Add(1)
+		go func(i, userID int) {
+			defer wg.Done()
+			if _, err := db.LikeChirp(chirp.ID, userID); err != nil {
+				errs <- fmt.Errorf("LikeChirp: %w", err)
+				return
+			}
+			if _, err := db.Rechirp(chirp.ID, userID); err != nil {
+				errs <- fmt.Errorf("Rechirp: %w", err)
+			}
+			// Every other user takes their like back again.
+			if i%2 == 0 {
+				if _, err := db.UnlikeChirp(chirp.ID, userID); err != nil {
+					errs <- fmt.Errorf("UnlikeChirp: %w", err)
+				}
+			}
+		}(i, user.ID)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		t.Fatalf("loadDB: %v", err)
+	}
+	stored := dbStruct.Chirps[chirp.ID]
+	if stored.LikeCount != len(dbStruct.Likes[chirp.ID]) || stored.LikeCount != users/2 {
+		t.Errorf("LikeCount = %d with %d likes stored, want %d",
+			stored.LikeCount, len(dbStruct.Likes[chirp.ID]), users/2)
+	}
+	if stored.RechirpCount != len(dbStruct.Rechirps[chirp.ID]) || stored.RechirpCount != users {
+		t.Errorf("RechirpCount = %d with %d rechirps stored, want %d",
+			stored.RechirpCount, len(dbStruct.Rechirps[chirp.ID]), users)
+	}
+}
+
+func TestDisengageNeedsAccess(t *testing.T) {
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	fan := addTestUser(t, db, "fan")
+	chirp := addTestChirp(t, db, Chirp{AuthorID: author.ID})
+
+	if _, err := db.LikeChirp(chirp.ID, fan.ID); err != nil {
+		t.Fatalf("LikeChirp: %v", err)
+	}
+	if err := db.BlockUser(author.ID, fan.ID); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+	got, err := db.UnlikeChirp(chirp.ID, fan.ID)
+	if !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("UnlikeChirp by a blocked user = %+v, %v, want ErrChirpNotFound", got, err)
+	}
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// FlagChirp records content filter flags against a chirp and puts it in the
+// moderation queue, unless it is already waiting there for the same reason.
+func (db *DB) FlagChirp(chirpID int, flags []ContentFlag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	return db.update(func(dbStruct *DBStructure) error {
+		chirp, ok := dbStruct.Chirps[chirpID]
+		if !ok {
+			return ErrChirpNotFound
+		}
+		now := time.Now().UTC()
+		rules := make([]string, 0, len(flags))
+		for _, flag := range flags {
+			if flag.CreatedAt.IsZero() {
+				flag.CreatedAt = now
+			}
+			dbStruct.ContentFlags[chirpID] = append(dbStruct.ContentFlags[chirpID], flag)
+			if !containsString(rules, flag.Rule) {
+				rules = append(rules, flag.Rule)
+			}
+		}
+
+		for _, report := range dbStruct.Reports {
+			if report.ChirpID == chirpID && report.Reason == ReportReasonContentFilter &&
+				report.Status != ReportResolved {
+				return nil
+			}
+		}
+		dbStruct.addReport(Report{
+			TargetType: ReportTargetChirp,
+			ChirpID:    chirpID,
+			UserID:     chirp.AuthorID,
+			Reason:     ReportReasonContentFilter,
+			Comment:    "Flagged by " + strings.Join(rules, ", "),
+		}, now)
+		return nil
+	})
+}
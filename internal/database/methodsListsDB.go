@@ -0,0 +1,251 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxListNameLength = 25
+	maxListMembers    = 500
+)
+
+var (
+	ErrListNotFound        = errors.New("List doesn't exist")
+	ErrNotListOwner        = errors.New("List does not belong to user")
+	ErrInvalidListName     = fmt.Errorf("List names must be 1 to %d characters", maxListNameLength)
+	ErrTooManyListMembers  = fmt.Errorf("A list can have at most %d members", maxListMembers)
+	ErrOwnListSubscription = errors.New("Users cannot subscribe to their own lists")
+)
+
+// ListChanges describes an update to a list. Nil fields are left alone.
+type ListChanges struct {
+	Name        *string
+	Description *string
+	Private     *bool
+}
+
+func (db *DB) CreateList(ownerID int, name, description string, private bool) (List, error) {
+	name, err := checkListName(name)
+	if err != nil {
+		return List{}, err
+	}
+
+	var list List
+	err = db.update(func(dbStruct *DBStructure) error {
+		now := time.Now().UTC()
+		id := dbStruct.LastListID + 1
+		list = List{
+			ID:          id,
+			OwnerID:     ownerID,
+			Name:        name,
+			Description: description,
+			Private:     private,
+			MemberIDs:   make([]int, 0),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		dbStruct.Lists[id] = list
+		dbStruct.LastListID = id
+		list = list.presentTo(ownerID)
+		return nil
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return list, nil
+}
+
+// GetList returns a list viewerID can see: any public list, or one of
+// their own private lists.
+func (db *DB) GetList(id, viewerID int) (List, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return List{}, err
+	}
+	list, ok := dbStruct.Lists[id]
+	if !ok || !list.visibleTo(viewerID) {
+		return List{}, ErrListNotFound
+	}
+	return list.presentTo(viewerID), nil
+}
+
+// GetLists returns the lists userID owns or subscribes to, in ID order.
+func (db *DB) GetLists(userID int) ([]List, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	lists := make([]List, 0)
+	for _, list := range dbStruct.Lists {
+		if list.OwnerID == userID || (list.visibleTo(userID) && containsID(list.SubscriberIDs, userID)) {
+			lists = append(lists, list.presentTo(userID))
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+	return lists, nil
+}
+
+// UpdateList changes one of ownerID's lists. Making a list private drops its
+// subscribers.
+func (db *DB) UpdateList(id, ownerID int, changes ListChanges) (List, error) {
+	if changes.Name != nil {
+		name, err := checkListName(*changes.Name)
+		if err != nil {
+			return List{}, err
+		}
+		changes.Name = &name
+	}
+	return db.changeList(id, ownerID, func(dbStruct *DBStructure, list *List) error {
+		if changes.Name != nil {
+			list.Name = *changes.Name
+		}
+		if changes.Description != nil {
+			list.Description = *changes.Description
+		}
+		if changes.Private != nil {
+			list.Private = *changes.Private
+			if list.Private {
+				list.SubscriberIDs = nil
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) DeleteList(id, ownerID int) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		list, ok := dbStruct.Lists[id]
+		if !ok || !list.visibleTo(ownerID) {
+			return ErrListNotFound
+		}
+		if list.OwnerID != ownerID {
+			return ErrNotListOwner
+		}
+		delete(dbStruct.Lists, id)
+		return nil
+	})
+}
+
+func (db *DB) AddListMember(id, ownerID, memberID int) (List, error) {
+	return db.changeList(id, ownerID, func(dbStruct *DBStructure, list *List) error {
+		if _, ok := dbStruct.Users[memberID]; !ok {
+			return ErrUserNotFound
+		}
+		if containsID(list.MemberIDs, memberID) {
+			return nil
+		}
+		if len(list.MemberIDs) >= maxListMembers {
+			return ErrTooManyListMembers
+		}
+		list.MemberIDs = append(list.MemberIDs, memberID)
+		return nil
+	})
+}
+
+func (db *DB) RemoveListMember(id, ownerID, memberID int) (List, error) {
+	return db.changeList(id, ownerID, func(dbStruct *DBStructure, list *List) error {
+		list.MemberIDs = removeID(list.MemberIDs, memberID)
+		return nil
+	})
+}
+
+// SubscribeToList subscribes userID to someone else's public list.
+func (db *DB) SubscribeToList(id, userID int) (List, error) {
+	return db.subscription(id, userID, func(list *List) {
+		if !containsID(list.SubscriberIDs, userID) {
+			list.SubscriberIDs = append(list.SubscriberIDs, userID)
+		}
+	})
+}
+
+func (db *DB) UnsubscribeFromList(id, userID int) (List, error) {
+	return db.subscription(id, userID, func(list *List) {
+		list.SubscriberIDs = removeID(list.SubscriberIDs, userID)
+		if len(list.SubscriberIDs) == 0 {
+			list.SubscriberIDs = nil
+		}
+	})
+}
+
+func (db *DB) subscription(id, userID int, change func(list *List)) (List, error) {
+	var list List
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		list, ok = dbStruct.Lists[id]
+		if !ok || !list.visibleTo(userID) {
+			return ErrListNotFound
+		}
+		if list.OwnerID == userID {
+			return ErrOwnListSubscription
+		}
+		change(&list)
+		dbStruct.Lists[id] = list
+		list = list.presentTo(userID)
+		return nil
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return list, nil
+}
+
+// changeList applies change to one of ownerID's lists. Lists the caller
+// can't see are reported as missing, and lists they can see but don't own
+// as not theirs.
+func (db *DB) changeList(
+	id, ownerID int,
+	change func(dbStruct *DBStructure, list *List) error,
+) (List, error) {
+	var list List
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		list, ok = dbStruct.Lists[id]
+		if !ok || !list.visibleTo(ownerID) {
+			return ErrListNotFound
+		}
+		if list.OwnerID != ownerID {
+			return ErrNotListOwner
+		}
+		err := change(dbStruct, &list)
+		if err != nil {
+			return err
+		}
+		list.UpdatedAt = time.Now().UTC()
+		dbStruct.Lists[id] = list
+		list = list.presentTo(ownerID)
+		return nil
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return list, nil
+}
+
+func (list List) visibleTo(viewerID int) bool {
+	return !list.Private || (viewerID != 0 && list.OwnerID == viewerID)
+}
+
+// presentTo fills in the fields of a list that depend on who reads it.
+func (list List) presentTo(viewerID int) List {
+	list.MemberIDs = append(make([]int, 0, len(list.MemberIDs)), list.MemberIDs...)
+	list.SubscriberCount = len(list.SubscriberIDs)
+	list.Subscribed = viewerID != 0 && containsID(list.SubscriberIDs, viewerID)
+	if list.OwnerID == viewerID {
+		list.SubscriberIDs = append([]int{}, list.SubscriberIDs...)
+	} else {
+		list.SubscriberIDs = nil
+	}
+	return list
+}
+
+func checkListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxListNameLength {
+		return "", ErrInvalidListName
+	}
+	return name, nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"time"
+)
+
+// maxChirpMedia is how many uploads can be attached to one chirp.
+const maxChirpMedia = 4
+
+// AddMedia records an upload whose files are already in the blob store.
+func (db *DB) AddMedia(media Media) (Media, error) {
+	err := db.update(func(dbStruct *DBStructure) error {
+		if _, ok := dbStruct.Users[media.OwnerID]; !ok {
+			return ErrUserNotFound
+		}
+		media.CreatedAt = time.Now().UTC()
+		dbStruct.Media[media.ID] = media
+		return nil
+	})
+	if err != nil {
+		return Media{}, err
+	}
+	return media, nil
+}
+
+func (db *DB) GetMedia(id string) (Media, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Media{}, err
+	}
+	media, ok := dbStruct.Media[id]
+	if !ok {
+		return Media{}, ErrMediaNotFound
+	}
+	media.Public = dbStruct.mediaPublic(id)
+	return media, nil
+}
+
+// mediaPublic reports whether the upload is attached to a public chirp that
+// anyone can read.
+func (dbStruct DBStructure) mediaPublic(id string) bool {
+	for _, chirp := range dbStruct.Chirps {
+		if chirp.Visibility == VisibilityPublic && containsString(chirp.MediaIDs, id) &&
+			dbStruct.chirpVisibleTo(0, chirp) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, target string) bool {
+	for _, val := range values {
+		if val == target {
+			return true
+		}
+	}
+	return false
+}
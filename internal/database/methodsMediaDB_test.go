@@ -0,0 +1,56 @@
+package database
+
+import "testing"
+
+func TestMediaPublic(t *testing.T) {
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	moderator := addTestUser(t, db, "moderator")
+	reporter := addTestUser(t, db, "reporter")
+	addMedia := func(id string) string {
+		t.Helper()
+		if _, err := db.AddMedia(Media{ID: id, OwnerID: author.ID}); err != nil {
+			t.Fatalf("AddMedia: %v", err)
+		}
+		return id
+	}
+
+	unattached := addMedia("unattached")
+	public := addMedia("public")
+	addTestChirp(t, db, Chirp{AuthorID: author.ID, MediaIDs: []string{public}})
+	followers := addMedia("followers")
+	addTestChirp(t, db, Chirp{AuthorID: author.ID, MediaIDs: []string{followers}, Visibility: VisibilityFollowers})
+	draft := addMedia("draft")
+	addTestChirp(t, db, Chirp{AuthorID: author.ID, MediaIDs: []string{draft}, Status: ChirpDraft})
+	hidden := addMedia("hidden")
+	hiddenChirp := addTestChirp(t, db, Chirp{AuthorID: author.ID, MediaIDs: []string{hidden}})
+	report, err := db.ReportChirp(reporter.ID, hiddenChirp.ID, "spam", "")
+	if err != nil {
+		t.Fatalf("ReportChirp: %v", err)
+	}
+	if _, err := db.ResolveReport(report.ID, moderator.ID, Resolution{Action: ResolutionHide}); err != nil {
+		t.Fatalf("ResolveReport: %v", err)
+	}
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{unattached, false},
+		{public, true},
+		{followers, false},
+		{draft, false},
+		{hidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			media, err := db.GetMedia(tt.id)
+			if err != nil {
+				t.Fatalf("GetMedia: %v", err)
+			}
+			if media.Public != tt.want {
+				t.Errorf("Public = %v, want %v", media.Public, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// maxConversationMembers caps the size of group conversations, counting the
+// user who starts them.
+const maxConversationMembers = 8
+
+var (
+	ErrConversationNotFound = errors.New("Conversation doesn't exist")
+	ErrMessageNotFound      = errors.New("Message doesn't exist")
+	ErrNoRecipients         = errors.New("A conversation needs at least one other member")
+	ErrTooManyMembers       = errors.New("A conversation can have at most 8 members")
+	ErrMessageBlocked       = errors.New("Users who have blocked each other cannot message each other")
+)
+
+// CreateConversation starts a conversation between creatorID and memberIDs.
+// A one-to-one conversation that already exists is returned instead of
+// starting another, and created reports which happened.
+func (db *DB) CreateConversation(creatorID int, memberIDs []int) (conversation Conversation, created bool, err error) {
+	members := []int{creatorID}
+	for _, id := range memberIDs {
+		if !containsID(members, id) {
+			members = append(members, id)
+		}
+	}
+	if len(members) < 2 {
+		return Conversation{}, false, ErrNoRecipients
+	}
+	if len(members) > maxConversationMembers {
+		return Conversation{}, false, ErrTooManyMembers
+	}
+	sort.Ints(members)
+
+	err = db.update(func(dbStruct *DBStructure) error {
+		for _, id := range members {
+			if _, ok := dbStruct.Users[id]; !ok {
+				return ErrUserNotFound
+			}
+			if dbStruct.blockedEitherWay(creatorID, id) {
+				return ErrMessageBlocked
+			}
+		}
+
+		if len(members) == 2 {
+			for _, existing := range dbStruct.Conversations {
+				if sameIDs(existing.MemberIDs, members) {
+					conversation = dbStruct.presentConversation(creatorID, existing)
+					return nil
+				}
+			}
+		}
+
+		now := time.Now().UTC()
+		id := dbStruct.LastConversationID + 1
+		stored := Conversation{
+			ID:            id,
+			MemberIDs:     members,
+			LastMessageAt: now,
+			ReadReceipts:  make([]ReadReceipt, 0),
+			CreatedAt:     now,
+		}
+		dbStruct.Conversations[id] = stored
+		dbStruct.LastConversationID = id
+		conversation = dbStruct.presentConversation(creatorID, stored)
+		created = true
+		return nil
+	})
+	if err != nil {
+		return Conversation{}, false, err
+	}
+	return conversation, created, nil
+}
+
+// GetConversation returns one of userID's conversations.
+func (db *DB) GetConversation(id, userID int) (Conversation, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Conversation{}, err
+	}
+	conversation, ok := dbStruct.Conversations[id]
+	if !ok || !containsID(conversation.MemberIDs, userID) {
+		return Conversation{}, ErrConversationNotFound
+	}
+	return dbStruct.presentConversation(userID, conversation), nil
+}
+
+// GetConversations returns a page of userID's conversations, the one with
+// the latest message first, and whether more follow. after is the position
+// of the last conversation of the previous page, as returned by
+// Conversation.Position.
+func (db *DB) GetConversations(userID int, after *Position, limit int) ([]Conversation, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	conversations := make([]Conversation, 0)
+	for _, conversation := range dbStruct.Conversations {
+		if !containsID(conversation.MemberIDs, userID) {
+			continue
+		}
+		if after != nil && !conversation.Position().before(*after) {
+			continue
+		}
+		conversations = append(conversations, conversation)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[j].Position().before(conversations[i].Position())
+	})
+
+	hasMore := len(conversations) > limit
+	if hasMore {
+		conversations = conversations[:limit]
+	}
+	for i, conversation := range conversations {
+		conversations[i] = dbStruct.presentConversation(userID, conversation)
+	}
+	return conversations, hasMore, nil
+}
+
+// SendMessage adds a message from senderID to a conversation they are a
+// member of. Sending a message also marks the conversation read up to it for
+// the sender.
+func (db *DB) SendMessage(conversationID, senderID int, body string) (Message, error) {
+	var message Message
+	err := db.update(func(dbStruct *DBStructure) error {
+		conversation, ok := dbStruct.Conversations[conversationID]
+		if !ok || !containsID(conversation.MemberIDs, senderID) {
+			return ErrConversationNotFound
+		}
+		for _, memberID := range conversation.MemberIDs {
+			if dbStruct.blockedEitherWay(senderID, memberID) {
+				return ErrMessageBlocked
+			}
+		}
+
+		now := time.Now().UTC()
+		message = Message{
+			ID:             dbStruct.LastMessageID + 1,
+			ConversationID: conversationID,
+			SenderID:       senderID,
+			Body:           body,
+			CreatedAt:      now,
+		}
+		dbStruct.LastMessageID = message.ID
+		dbStruct.Messages[conversationID] = append(dbStruct.Messages[conversationID], message)
+
+		conversation.LastMessageAt = now
+		conversation.ReadReceipts = setReadReceipt(conversation.ReadReceipts, senderID, message.ID, now)
+		dbStruct.Conversations[conversationID] = conversation
+		return nil
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	return message, nil
+}
+
+// GetMessages returns a page of a conversation's messages, newest first,
+// and whether older messages follow.
+func (db *DB) GetMessages(conversationID, userID int, after *Position, limit int) ([]Message, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, false, err
+	}
+	conversation, ok := dbStruct.Conversations[conversationID]
+	if !ok || !containsID(conversation.MemberIDs, userID) {
+		return nil, false, ErrConversationNotFound
+	}
+
+	stored := dbStruct.Messages[conversationID]
+	messages := make([]Message, 0, limit+1)
+	for i := len(stored) - 1; i >= 0 && len(messages) <= limit; i-- {
+		if after != nil && !stored[i].Position().before(*after) {
+			continue
+		}
+		messages = append(messages, stored[i])
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	return messages, hasMore, nil
+}
+
+// MarkConversationRead records that userID has read a conversation up to
+// messageID, or up to its latest message when messageID is 0. Receipts only
+// move forward.
+func (db *DB) MarkConversationRead(conversationID, userID, messageID int) (Conversation, error) {
+	var conversation Conversation
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		conversation, ok = dbStruct.Conversations[conversationID]
+		if !ok || !containsID(conversation.MemberIDs, userID) {
+			return ErrConversationNotFound
+		}
+
+		messages := dbStruct.Messages[conversationID]
+		if messageID == 0 {
+			if len(messages) == 0 {
+				conversation = dbStruct.presentConversation(userID, conversation)
+				return nil
+			}
+			messageID = messages[len(messages)-1].ID
+		}
+		found := false
+		for _, message := range messages {
+			if message.ID == messageID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrMessageNotFound
+		}
+
+		if messageID > conversation.lastReadBy(userID) {
+			now := time.Now().UTC()
+			conversation.ReadReceipts = setReadReceipt(conversation.ReadReceipts, userID, messageID, now)
+			dbStruct.Conversations[conversationID] = conversation
+		}
+		conversation = dbStruct.presentConversation(userID, conversation)
+		return nil
+	})
+	if err != nil {
+		return Conversation{}, err
+	}
+	return conversation, nil
+}
+
+// Position returns where the conversation sits in GetConversations order.
+func (c Conversation) Position() Position {
+	return Position{CreatedAt: c.LastMessageAt, ID: c.ID}
+}
+
+// Position returns where the message sits in GetMessages order.
+func (m Message) Position() Position {
+	return Position{CreatedAt: m.CreatedAt, ID: m.ID}
+}
+
+// lastReadBy returns the ID of the latest message userID has read, or 0.
+func (c Conversation) lastReadBy(userID int) int {
+	for _, receipt := range c.ReadReceipts {
+		if receipt.UserID == userID {
+			return receipt.MessageID
+		}
+	}
+	return 0
+}
+
+// presentConversation fills in the fields of a conversation that depend on
+// the member reading it.
+func (dbStruct DBStructure) presentConversation(viewerID int, conversation Conversation) Conversation {
+	conversation.ReadReceipts = append([]ReadReceipt{}, conversation.ReadReceipts...)
+	conversation.LastMessage = nil
+	conversation.UnreadCount = 0
+
+	messages := dbStruct.Messages[conversation.ID]
+	if len(messages) > 0 {
+		last := messages[len(messages)-1]
+		conversation.LastMessage = &last
+	}
+	lastRead := conversation.lastReadBy(viewerID)
+	for _, message := range messages {
+		if message.ID > lastRead && message.SenderID != viewerID {
+			conversation.UnreadCount++
+		}
+	}
+	return conversation
+}
+
+func setReadReceipt(receipts []ReadReceipt, userID, messageID int, now time.Time) []ReadReceipt {
+	updated := make([]ReadReceipt, 0, len(receipts)+1)
+	for _, receipt := range receipts {
+		if receipt.UserID != userID {
+			updated = append(updated, receipt)
+		}
+	}
+	return append(updated, ReadReceipt{UserID: userID, MessageID: messageID, ReadAt: now})
+}
+
+func sameIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
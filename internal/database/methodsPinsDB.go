@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAlreadyPinned = errors.New("Chirp is already pinned")
+	ErrNotPinned     = errors.New("Chirp isn't pinned")
+	ErrTooManyPins   = errors.New("User has pinned as many chirps as they are allowed to")
+)
+
+// PinChirp pins one of userID's published chirps to the top of their feed.
+// limit and limitRed are how many pins regular and Chirpy Red users may
+// have. The user's tier is checked in the same update as the pin, so a
+// downgrade can't slip in between.
+func (db *DB) PinChirp(chirpID, userID, limit, limitRed int) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		chirp, ok := dbStruct.Chirps[chirpID]
+		if !ok || !dbStruct.chirpVisibleTo(userID, chirp) {
+			return ErrChirpNotFound
+		}
+		if chirp.AuthorID != userID {
+			return ErrNotChirpAuthor
+		}
+		pins := dbStruct.Pins[userID]
+		for _, pin := range pins {
+			if pin.ChirpID == chirpID {
+				return ErrAlreadyPinned
+			}
+		}
+		if dbStruct.Users[userID].ChirpyRed {
+			limit = limitRed
+		}
+		if len(pins) >= limit {
+			return ErrTooManyPins
+		}
+		dbStruct.Pins[userID] = append(pins, Pin{ChirpID: chirpID, CreatedAt: time.Now().UTC()})
+		return nil
+	})
+}
+
+func (db *DB) UnpinChirp(chirpID, userID int) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		if !dbStruct.unpin(userID, chirpID) {
+			return ErrNotPinned
+		}
+		return nil
+	})
+}
+
+// unpin removes a pin, reporting whether there was one.
+func (dbStruct *DBStructure) unpin(userID, chirpID int) bool {
+	pins := dbStruct.Pins[userID]
+	for i, pin := range pins {
+		if pin.ChirpID != chirpID {
+			continue
+		}
+		pins = append(pins[:i:i], pins[i+1:]...)
+		if len(pins) == 0 {
+			delete(dbStruct.Pins, userID)
+		} else {
+			dbStruct.Pins[userID] = pins
+		}
+		return true
+	}
+	return false
+}
+
+// trimPins unpins the user's oldest pins until at most limit remain.
+func (dbStruct *DBStructure) trimPins(userID, limit int) {
+	pins := dbStruct.Pins[userID]
+	if len(pins) <= limit {
+		return
+	}
+	pins = append([]Pin{}, pins[len(pins)-limit:]...)
+	if len(pins) == 0 {
+		delete(dbStruct.Pins, userID)
+		return
+	}
+	dbStruct.Pins[userID] = pins
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPinLimitFollowsTier(t *testing.T) {
+	const limit, limitRed = 1, 3
+
+	db := newTestDB(t)
+	user := addTestUser(t, db, "user")
+	chirps := make([]Chirp, 4)
+	for i := range chirps {
+		chirps[i] = addTestChirp(t, db, Chirp{AuthorID: user.ID})
+	}
+
+	if err := db.PinChirp(chirps[0].ID, user.ID, limit, limitRed); err != nil {
+		t.Fatalf("PinChirp: %v", err)
+	}
+	if err := db.PinChirp(chirps[1].ID, user.ID, limit, limitRed); !errors.Is(err, ErrTooManyPins) {
+		t.Fatalf("PinChirp past the regular limit error = %v, want ErrTooManyPins", err)
+	}
+
+	if err := db.UpgradeUser(user.ID); err != nil {
+		t.Fatalf("UpgradeUser: %v", err)
+	}
+	for _, chirp := range chirps[1:3] {
+		if err := db.PinChirp(chirp.ID, user.ID, limit, limitRed); err != nil {
+			t.Fatalf("PinChirp as Chirpy Red: %v", err)
+		}
+	}
+	if err := db.PinChirp(chirps[3].ID, user.ID, limit, limitRed); !errors.Is(err, ErrTooManyPins) {
+		t.Fatalf("PinChirp past the Chirpy Red limit error = %v, want ErrTooManyPins", err)
+	}
+
+	if err := db.DowngradeUser(user.ID, limit); err != nil {
+		t.Fatalf("DowngradeUser: %v", err)
+	}
+	if err := db.PinChirp(chirps[3].ID, user.ID, limit, limitRed); !errors.Is(err, ErrTooManyPins) {
+		t.Errorf("PinChirp after downgrading error = %v, want ErrTooManyPins", err)
+	}
+}
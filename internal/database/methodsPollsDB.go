@@ -0,0 +1,186 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	minPollOptions    = 2
+	maxPollOptions    = 4
+	maxPollOptionLen  = 25
+	minPollDuration   = 5 * time.Minute
+	maxPollDuration   = 7 * 24 * time.Hour
+	pollDurationLabel = "between 5 minutes and 7 days"
+)
+
+var (
+	ErrPollNotFound      = errors.New("Chirp doesn't have a poll")
+	ErrPollClosed        = errors.New("Poll has closed")
+	ErrAlreadyVoted      = errors.New("User has already voted in this poll")
+	ErrInvalidPollOption = errors.New("Poll doesn't have that option")
+)
+
+// Poll is a set of options attached to a chirp. It runs for
+// DurationMinutes from when the chirp is published.
+type Poll struct {
+	Options         []string `json:"options"`
+	DurationMinutes int      `json:"duration_minutes"`
+	// ExpiresAt is set when the chirp is published.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Closed    bool       `json:"closed"`
+	// Counts holds the votes for each option. Viewers only see them once
+	// they have voted or the poll has closed.
+	Counts     []int `json:"counts,omitempty"`
+	TotalVotes int   `json:"total_votes,omitempty"`
+	// Voted is the option the viewer voted for, filled in when read.
+	Voted *int `json:"voted,omitempty"`
+}
+
+// PollVote is one user's vote, for Options[Option].
+type PollVote struct {
+	UserID    int       `json:"user_id"`
+	Option    int       `json:"option"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewPoll validates the options and duration of a poll to attach to a new
+// chirp.
+func NewPoll(options []string, duration time.Duration) (*Poll, error) {
+	if len(options) < minPollOptions || len(options) > maxPollOptions {
+		return nil, fmt.Errorf("A poll needs %d to %d options", minPollOptions, maxPollOptions)
+	}
+	cleaned := make([]string, len(options))
+	for i, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || len([]rune(option)) > maxPollOptionLen {
+			return nil, fmt.Errorf("Poll options must be 1 to %d characters", maxPollOptionLen)
+		}
+		for _, previous := range cleaned[:i] {
+			if strings.EqualFold(previous, option) {
+				return nil, errors.New("Poll options must be different")
+			}
+		}
+		cleaned[i] = option
+	}
+	if duration < minPollDuration || duration > maxPollDuration {
+		return nil, errors.New("A poll must run for " + pollDurationLabel)
+	}
+	return &Poll{Options: cleaned, DurationMinutes: int(duration / time.Minute)}, nil
+}
+
+// Vote records userID's vote in a chirp's poll. Each user votes once and
+// can't change their vote.
+func (db *DB) Vote(chirpID, userID, option int) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStruct.Chirps[chirpID]
+		if !ok || !dbStruct.chirpVisibleTo(userID, chirp) {
+			return ErrChirpNotFound
+		}
+		if chirp.Poll == nil {
+			return ErrPollNotFound
+		}
+		now := time.Now().UTC()
+		if chirp.Poll.closedAt(now) {
+			return ErrPollClosed
+		}
+		if option < 0 || option >= len(chirp.Poll.Options) {
+			return ErrInvalidPollOption
+		}
+		for _, vote := range dbStruct.PollVotes[chirpID] {
+			if vote.UserID == userID {
+				return ErrAlreadyVoted
+			}
+		}
+
+		dbStruct.PollVotes[chirpID] = append(dbStruct.PollVotes[chirpID], PollVote{
+			UserID:    userID,
+			Option:    option,
+			CreatedAt: now,
+		})
+		poll := *chirp.Poll
+		poll.Counts = append([]int{}, poll.Counts...)
+		poll.Counts[option]++
+		chirp.Poll = &poll
+		dbStruct.Chirps[chirpID] = chirp
+		chirp = dbStruct.presentChirp(userID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// CloseExpiredPolls marks the polls that expired at or before now as
+// closed, returning their chirps.
+func (db *DB) CloseExpiredPolls(now time.Time) ([]Chirp, error) {
+	closed := make([]Chirp, 0)
+	err := db.update(func(dbStruct *DBStructure) error {
+		for id, chirp := range dbStruct.Chirps {
+			if chirp.Poll == nil || chirp.Poll.Closed || chirp.Poll.ExpiresAt == nil ||
+				chirp.Poll.ExpiresAt.After(now) {
+				continue
+			}
+			poll := *chirp.Poll
+			poll.Closed = true
+			chirp.Poll = &poll
+			dbStruct.Chirps[id] = chirp
+			closed = append(closed, chirp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+// startPoll starts the chirp's poll running as the chirp is published.
+func (chirp *Chirp) startPoll(now time.Time) {
+	if chirp.Poll == nil {
+		return
+	}
+	poll := *chirp.Poll
+	expiresAt := now.Add(time.Duration(poll.DurationMinutes) * time.Minute)
+	poll.ExpiresAt = &expiresAt
+	chirp.Poll = &poll
+}
+
+// closedAt reports whether the poll is closed at now, whether or not it has
+// been marked closed yet.
+func (p *Poll) closedAt(now time.Time) bool {
+	return p.Closed || (p.ExpiresAt != nil && !p.ExpiresAt.After(now))
+}
+
+// presentPoll returns a copy of the chirp's poll as viewerID may see it.
+func (dbStruct DBStructure) presentPoll(viewerID int, chirp Chirp) *Poll {
+	if chirp.Poll == nil {
+		return nil
+	}
+	poll := *chirp.Poll
+	poll.Closed = poll.closedAt(time.Now().UTC())
+	poll.Voted = nil
+	for _, vote := range dbStruct.PollVotes[chirp.ID] {
+		if vote.UserID == viewerID && viewerID != 0 {
+			option := vote.Option
+			poll.Voted = &option
+			break
+		}
+	}
+
+	poll.TotalVotes = 0
+	if !poll.Closed && poll.Voted == nil {
+		poll.Counts = nil
+		return &poll
+	}
+	poll.Counts = append([]int{}, poll.Counts...)
+	for _, count := range poll.Counts {
+		poll.TotalVotes += count
+	}
+	return &poll
+}
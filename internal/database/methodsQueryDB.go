@@ -0,0 +1,191 @@
+package database
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// ChirpQuery describes a filtered, ordered page of chirps.
+type ChirpQuery struct {
+	ViewerID int
+	AuthorID int
+	// PinnedFirst puts the author's pinned chirps at the top of the first
+	// page of an author feed, on top of Limit, and leaves them out of the
+	// rest of the feed.
+	PinnedFirst bool
+	// AuthorIDs restricts the query to chirps by any of these authors, for
+	// timelines. Like author feeds, it includes the chirps they rechirped.
+	AuthorIDs []int
+	// Tag and MentionedUserID restrict the query to chirps with that
+	// hashtag or mention, answered from the indexes.
+	Tag             string
+	MentionedUserID int
+	SortField       string
+	SortDesc        bool
+	Since           time.Time
+	Until           time.Time
+	// After is the position of the last chirp of the previous page.
+	After *Position
+	// Limit caps the page size; 0 returns every matching chirp.
+	Limit int
+}
+
+// Position identifies an item in a list ordered by creation time, with the
+// ID breaking ties.
+type Position struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// QueryChirps returns the chirps matching query in order, and whether more
+// chirps follow the returned page. Only Limit+1 candidates are held at a
+// time, so a page never copies the whole table.
+func (db *DB) QueryChirps(query ChirpQuery) ([]Chirp, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	candidates := dbStruct.Chirps
+	if query.Tag != "" {
+		candidates = dbStruct.chirpsByID(dbStruct.TagIndex[NormalizeTag(query.Tag)])
+	}
+	if query.MentionedUserID != 0 {
+		candidates = dbStruct.chirpsByID(dbStruct.MentionIndex[query.MentionedUserID])
+	}
+
+	authors := query.AuthorIDs
+	if query.AuthorID != 0 {
+		authors = []int{query.AuthorID}
+	}
+
+	pinned := make([]Chirp, 0)
+	pinnedIDs := make([]int, 0)
+	if query.PinnedFirst && query.AuthorID != 0 {
+		pins := dbStruct.Pins[query.AuthorID]
+		for i := len(pins) - 1; i >= 0; i-- {
+			chirp, ok := dbStruct.Chirps[pins[i].ChirpID]
+			if !ok || !dbStruct.chirpVisibleTo(query.ViewerID, chirp) {
+				continue
+			}
+			pinnedIDs = append(pinnedIDs, chirp.ID)
+			if query.After == nil && query.matches(chirp) {
+				chirp.Pinned = true
+				pinned = append(pinned, chirp)
+			}
+		}
+	}
+
+	page := &chirpHeap{query: query, chirps: make([]Chirp, 0)}
+	for _, chirp := range candidates {
+		if containsID(pinnedIDs, chirp.ID) {
+			continue
+		}
+		if authors != nil && !containsID(authors, chirp.AuthorID) {
+			// Author feeds and timelines include the chirps the authors
+			// rechirped.
+			rechirper := dbStruct.rechirperAmong(chirp.ID, authors)
+			if rechirper == 0 {
+				continue
+			}
+			chirp.RechirpedBy = rechirper
+		}
+		if !query.matches(chirp) || !query.shows(dbStruct, chirp) {
+			continue
+		}
+		if query.Limit == 0 || page.Len() <= query.Limit {
+			heap.Push(page, chirp)
+			continue
+		}
+		if query.less(chirp, page.chirps[0]) {
+			page.chirps[0] = chirp
+			heap.Fix(page, 0)
+		}
+	}
+
+	chirps := page.chirps
+	sort.Slice(chirps, func(i, j int) bool {
+		return query.less(chirps[i], chirps[j])
+	})
+
+	hasMore := query.Limit > 0 && len(chirps) > query.Limit
+	if hasMore {
+		chirps = chirps[:query.Limit]
+	}
+	chirps = append(pinned, chirps...)
+	return dbStruct.presentChirps(query.ViewerID, chirps), hasMore, nil
+}
+
+// Position returns where the chirp sits in a ChirpQuery ordering, for use
+// as the After of the next page.
+func (c Chirp) Position() Position {
+	return Position{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+func (dbStruct DBStructure) chirpsByID(ids []int) map[int]Chirp {
+	chirps := make(map[int]Chirp, len(ids))
+	for _, id := range ids {
+		if chirp, ok := dbStruct.Chirps[id]; ok {
+			chirps[id] = chirp
+		}
+	}
+	return chirps
+}
+
+// shows reports whether the viewer may see the chirp in the query's results.
+// Unlisted chirps only appear in author feeds, timelines and mentions, where
+// the viewer chose to follow the author or was mentioned, and not on the
+// global timeline or hashtag pages.
+func (query ChirpQuery) shows(dbStruct DBStructure, chirp Chirp) bool {
+	if query.AuthorID != 0 || query.AuthorIDs != nil || query.MentionedUserID != 0 {
+		return dbStruct.chirpVisibleTo(query.ViewerID, chirp)
+	}
+	return dbStruct.chirpListedTo(query.ViewerID, chirp)
+}
+
+func (query ChirpQuery) matches(chirp Chirp) bool {
+	if !query.Since.IsZero() && chirp.CreatedAt.Before(query.Since) {
+		return false
+	}
+	if !query.Until.IsZero() && !chirp.CreatedAt.Before(query.Until) {
+		return false
+	}
+	if query.After != nil {
+		after := Chirp{ID: query.After.ID, CreatedAt: query.After.CreatedAt}
+		return query.less(after, chirp)
+	}
+	return true
+}
+
+// less orders chirps by the query's sort field, breaking ties by ID.
+func (query ChirpQuery) less(a, b Chirp) bool {
+	if query.SortDesc {
+		a, b = b, a
+	}
+	if query.SortField == "created_at" && !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// chirpHeap keeps the last chirp in query order at its root so the page can
+// evict it when an earlier chirp turns up.
+type chirpHeap struct {
+	query  ChirpQuery
+	chirps []Chirp
+}
+
+func (h *chirpHeap) Len() int { return len(h.chirps) }
+
+func (h *chirpHeap) Less(i, j int) bool { return h.query.less(h.chirps[j], h.chirps[i]) }
+
+func (h *chirpHeap) Swap(i, j int) { h.chirps[i], h.chirps[j] = h.chirps[j], h.chirps[i] }
+
+func (h *chirpHeap) Push(x any) { h.chirps = append(h.chirps, x.(Chirp)) }
+
+func (h *chirpHeap) Pop() any {
+	last := h.chirps[len(h.chirps)-1]
+	h.chirps = h.chirps[:len(h.chirps)-1]
+	return last
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrSelfRelation  = errors.New("Users cannot follow, block or mute themselves")
+	ErrFollowBlocked = errors.New("Users who have blocked each other cannot follow each other")
+)
+
+// BlockUser blocks a user, which also ends any follows between the two.
+func (db *DB) BlockUser(blockerID, blockedID int) error {
+	return db.addRelation(blockerID, blockedID, func(dbStruct *DBStructure) map[int][]int {
+		dbStruct.unfollow(blockerID, blockedID)
+		dbStruct.unfollow(blockedID, blockerID)
+		return dbStruct.Blocks
+	})
+}
+
+func (db *DB) UnblockUser(blockerID, blockedID int) error {
+	return db.removeRelation(blockerID, blockedID, func(dbStruct *DBStructure) map[int][]int {
+		return dbStruct.Blocks
+	})
+}
+
+func (db *DB) MuteUser(muterID, mutedID int) error {
+	return db.addRelation(muterID, mutedID, func(dbStruct *DBStructure) map[int][]int {
+		return dbStruct.Mutes
+	})
+}
+
+func (db *DB) UnmuteUser(muterID, mutedID int) error {
+	return db.removeRelation(muterID, mutedID, func(dbStruct *DBStructure) map[int][]int {
+		return dbStruct.Mutes
+	})
+}
+
+// FollowUser makes followerID a follower of followedID, unless either has
+// blocked the other.
+func (db *DB) FollowUser(followerID, followedID int) error {
+	if followerID == followedID {
+		return ErrSelfRelation
+	}
+	return db.update(func(dbStruct *DBStructure) error {
+		if _, ok := dbStruct.Users[followedID]; !ok {
+			return ErrUserNotFound
+		}
+		if dbStruct.blockedEitherWay(followerID, followedID) {
+			return ErrFollowBlocked
+		}
+		if !containsID(dbStruct.Follows[followerID], followedID) {
+			dbStruct.Follows[followerID] = append(dbStruct.Follows[followerID], followedID)
+		}
+		return nil
+	})
+}
+
+func (db *DB) UnfollowUser(followerID, followedID int) error {
+	return db.removeRelation(followerID, followedID, func(dbStruct *DBStructure) map[int][]int {
+		return dbStruct.Follows
+	})
+}
+
+// GetFollowedUsers returns the users userID follows.
+func (db *DB) GetFollowedUsers(userID int) ([]User, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	return dbStruct.usersByID(dbStruct.Follows[userID]), nil
+}
+
+// GetFollowers returns the users following userID, in ID order.
+func (db *DB) GetFollowers(userID int) ([]User, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0)
+	for followerID, followed := range dbStruct.Follows {
+		if containsID(followed, userID) {
+			ids = append(ids, followerID)
+		}
+	}
+	sort.Ints(ids)
+	return dbStruct.usersByID(ids), nil
+}
+
+func (db *DB) GetBlockedUsers(userID int) ([]User, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	return dbStruct.usersByID(dbStruct.Blocks[userID]), nil
+}
+
+func (db *DB) GetMutedUsers(userID int) ([]User, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	return dbStruct.usersByID(dbStruct.Mutes[userID]), nil
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (db *DB) IsBlocked(userA, userB int) (bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return false, err
+	}
+	return dbStruct.blockedEitherWay(userA, userB), nil
+}
+
+func (db *DB) addRelation(
+	fromID, toID int,
+	relation func(dbStruct *DBStructure) map[int][]int,
+) error {
+	if fromID == toID {
+		return ErrSelfRelation
+	}
+	return db.update(func(dbStruct *DBStructure) error {
+		if _, ok := dbStruct.Users[toID]; !ok {
+			return ErrUserNotFound
+		}
+
+		relations := relation(dbStruct)
+		if !containsID(relations[fromID], toID) {
+			relations[fromID] = append(relations[fromID], toID)
+		}
+		return nil
+	})
+}
+
+func (db *DB) removeRelation(
+	fromID, toID int,
+	relation func(dbStruct *DBStructure) map[int][]int,
+) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		relations := relation(dbStruct)
+		relations[fromID] = removeID(relations[fromID], toID)
+		if len(relations[fromID]) == 0 {
+			delete(relations, fromID)
+		}
+		return nil
+	})
+}
+
+func (dbStruct *DBStructure) unfollow(followerID, followedID int) {
+	dbStruct.Follows[followerID] = removeID(dbStruct.Follows[followerID], followedID)
+	if len(dbStruct.Follows[followerID]) == 0 {
+		delete(dbStruct.Follows, followerID)
+	}
+}
+
+func (dbStruct DBStructure) blockedEitherWay(userA, userB int) bool {
+	return containsID(dbStruct.Blocks[userA], userB) || containsID(dbStruct.Blocks[userB], userA)
+}
+
+// chirpVisibleTo applies the chirp's visibility and the viewer's blocks,
+// mutes and sensitive content preference to a chirp. A viewerID of 0 is an
+// anonymous caller and sees every published chirp that anyone may read.
+// Drafts and scheduled chirps aren't visible through the read paths, even to
+// their authors, who manage them through the pending chirp methods instead,
+// and neither are chirps hidden by moderators or by their author's
+// suspension.
+func (dbStruct DBStructure) chirpVisibleTo(viewerID int, chirp Chirp) bool {
+	if !chirp.Published() || chirp.Hidden || dbStruct.Users[chirp.AuthorID].ChirpsHidden ||
+		!dbStruct.allowsViewer(viewerID, chirp) {
+		return false
+	}
+	if viewerID == 0 || viewerID == chirp.AuthorID {
+		return true
+	}
+	if chirp.flaggedSensitive() && dbStruct.sensitivePreference(viewerID) == SensitiveFilter {
+		return false
+	}
+	if dbStruct.blockedEitherWay(viewerID, chirp.AuthorID) {
+		return false
+	}
+	return !containsID(dbStruct.Mutes[viewerID], chirp.AuthorID)
+}
+
+func (dbStruct DBStructure) usersByID(ids []int) []User {
+	users := make([]User, 0, len(ids))
+	for _, id := range ids {
+		user, ok := dbStruct.Users[id]
+		if !ok {
+			continue
+		}
+		users = append(users, user.withoutPassword())
+	}
+	return users
+}
+
+func (u User) withoutPassword() User {
+	u.Password = nil
+	return u
+}
+
+func containsID(ids []int, id int) bool {
+	for _, val := range ids {
+		if val == id {
+			return true
+		}
+	}
+	return false
+}
+
+func removeID(ids []int, id int) []int {
+	result := make([]int, 0, len(ids))
+	for _, val := range ids {
+		if val != id {
+			result = append(result, val)
+		}
+	}
+	return result
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBlocksAndMutesHideChirps(t *testing.T) {
+	db := newTestDB(t)
+	blocker := addTestUser(t, db, "blocker")
+	blocked := addTestUser(t, db, "blocked")
+	muter := addTestUser(t, db, "muter")
+	muted := addTestUser(t, db, "muted")
+	bystander := addTestUser(t, db, "bystander")
+
+	chirps := make(map[int]Chirp)
+	for _, user := range []User{blocker, blocked, muter, muted} {
+		chirps[user.ID] = addTestChirp(t, db, Chirp{AuthorID: user.ID})
+	}
+	if err := db.BlockUser(blocker.ID, blocked.ID); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+	if err := db.MuteUser(muter.ID, muted.ID); err != nil {
+		t.Fatalf("MuteUser: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		viewerID int
+		authorID int
+		visible  bool
+	}{
+		{"blocked user can't see the blocker", blocked.ID, blocker.ID, false},
+		{"blocker can't see the blocked user", blocker.ID, blocked.ID, false},
+		{"muter doesn't see the muted user", muter.ID, muted.ID, false},
+		{"muted user still sees the muter", muted.ID, muter.ID, true},
+		{"others see the blocked user", bystander.ID, blocked.ID, true},
+		{"others see the muted user", bystander.ID, muted.ID, true},
+		{"anonymous callers see everyone", 0, blocker.ID, true},
+		{"authors see themselves", blocked.ID, blocked.ID, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chirp := chirps[tt.authorID]
+
+			_, err := db.GetChirpByID(chirp.ID, tt.viewerID)
+			if tt.visible && err != nil {
+				t.Errorf("GetChirpByID: %v", err)
+			}
+			if !tt.visible && !errors.Is(err, ErrChirpNotFound) {
+				t.Errorf("GetChirpByID error = %v, want ErrChirpNotFound", err)
+			}
+
+			queries := map[string]ChirpQuery{
+				"all chirps":   {ViewerID: tt.viewerID},
+				"author feed":  {ViewerID: tt.viewerID, AuthorID: tt.authorID},
+				"timeline":     {ViewerID: tt.viewerID, AuthorIDs: []int{tt.authorID}},
+				"paged chirps": {ViewerID: tt.viewerID, Limit: 10},
+			}
+			for name, query := range queries {
+				page, _, err := db.QueryChirps(query)
+				if err != nil {
+					t.Fatalf("QueryChirps(%s): %v", name, err)
+				}
+				found := false
+				for _, got := range page {
+					found = found || got.ID == chirp.ID
+				}
+				if found != tt.visible {
+					t.Errorf("%s includes the chirp: %v, want %v", name, found, tt.visible)
+				}
+			}
+		})
+	}
+}
+
+func TestBlocksPreventInteraction(t *testing.T) {
+	db := newTestDB(t)
+	blocker := addTestUser(t, db, "blocker")
+	blocked := addTestUser(t, db, "blocked")
+	bystander := addTestUser(t, db, "bystander")
+
+	byBlocker := addTestChirp(t, db, Chirp{AuthorID: blocker.ID})
+	byBlocked := addTestChirp(t, db, Chirp{AuthorID: blocked.ID})
+	for _, follow := range [][2]int{{blocker.ID, blocked.ID}, {blocked.ID, blocker.ID}} {
+		if err := db.FollowUser(follow[0], follow[1]); err != nil {
+			t.Fatalf("FollowUser: %v", err)
+		}
+	}
+	if err := db.BlockUser(blocker.ID, blocked.ID); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+
+	for _, userID := range []int{blocker.ID, blocked.ID} {
+		followed, err := db.GetFollowedUsers(userID)
+		if err != nil {
+			t.Fatalf("GetFollowedUsers: %v", err)
+		}
+		if len(followed) != 0 {
+			t.Errorf("user %d still follows %+v after the block", userID, followed)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		chirp   Chirp
+		wantErr error
+	}{
+		{"blocked user can't reply", Chirp{AuthorID: blocked.ID, InReplyTo: byBlocker.ID}, ErrParentNotFound},
+		{"blocker can't reply", Chirp{AuthorID: blocker.ID, InReplyTo: byBlocked.ID}, ErrParentNotFound},
+		{"blocked user can't quote", Chirp{AuthorID: blocked.ID, QuoteOf: byBlocker.ID}, ErrQuotedNotFound},
+		{"others can still reply", Chirp{AuthorID: bystander.ID, InReplyTo: byBlocker.ID}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.chirp.Body = "reply"
+			_, err := db.CreateChirp(tt.chirp)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateChirp error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	follows := []struct {
+		name       string
+		followerID int
+		followedID int
+		wantErr    error
+	}{
+		{"blocked user can't follow", blocked.ID, blocker.ID, ErrFollowBlocked},
+		{"blocker can't follow", blocker.ID, blocked.ID, ErrFollowBlocked},
+		{"users can't follow themselves", bystander.ID, bystander.ID, ErrSelfRelation},
+		{"others can still follow", bystander.ID, blocker.ID, nil},
+	}
+	for _, tt := range follows {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.FollowUser(tt.followerID, tt.followedID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("FollowUser error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRelationLists(t *testing.T) {
+	db := newTestDB(t)
+	alice := addTestUser(t, db, "alice")
+	bob := addTestUser(t, db, "bob")
+	carol := addTestUser(t, db, "carol")
+
+	steps := []struct {
+		name string
+		do   func() error
+	}{
+		{"block bob", func() error { return db.BlockUser(alice.ID, bob.ID) }},
+		{"block bob again", func() error { return db.BlockUser(alice.ID, bob.ID) }},
+		{"block carol", func() error { return db.BlockUser(alice.ID, carol.ID) }},
+		{"mute carol", func() error { return db.MuteUser(alice.ID, carol.ID) }},
+		{"unblock bob", func() error { return db.UnblockUser(alice.ID, bob.ID) }},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+	if err := db.MuteUser(alice.ID, alice.ID); !errors.Is(err, ErrSelfRelation) {
+		t.Errorf("muting yourself error = %v, want ErrSelfRelation", err)
+	}
+	if err := db.BlockUser(alice.ID, 99); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("blocking a missing user error = %v, want ErrUserNotFound", err)
+	}
+
+	lists := []struct {
+		name string
+		get  func(int) ([]User, error)
+		want []int
+	}{
+		{"blocked", db.GetBlockedUsers, []int{carol.ID}},
+		{"muted", db.GetMutedUsers, []int{carol.ID}},
+	}
+	for _, tt := range lists {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := tt.get(alice.ID)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			ids := make([]int, 0, len(users))
+			for _, user := range users {
+				ids = append(ids, user.ID)
+				if user.Password != nil {
+					t.Errorf("user %d is listed with their password hash", user.ID)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("%s users = %v, want %v", tt.name, ids, tt.want)
+			}
+		})
+	}
+
+	blocked, err := db.IsBlocked(carol.ID, alice.ID)
+	if err != nil || !blocked {
+		t.Errorf("IsBlocked(carol, alice) = %v, %v; want true", blocked, err)
+	}
+	blocked, err = db.IsBlocked(alice.ID, bob.ID)
+	if err != nil || blocked {
+		t.Errorf("IsBlocked(alice, bob) after unblocking = %v, %v; want false", blocked, err)
+	}
+}
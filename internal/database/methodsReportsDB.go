@@ -0,0 +1,399 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ReportTargetChirp = "chirp"
+	ReportTargetUser  = "user"
+
+	ReportOpen     = "open"
+	ReportClaimed  = "claimed"
+	ReportResolved = "resolved"
+
+	// ReportReasonContentFilter is the reason on reports the content filter
+	// raises. Users can't pick it.
+	ReportReasonContentFilter = "content_filter"
+)
+
+// Resolutions a moderator can pick for a report.
+const (
+	ResolutionDismiss = "dismiss"
+	ResolutionHide    = "hide"
+	ResolutionWarn    = "warn"
+	ResolutionSuspend = "suspend"
+)
+
+// Moderation actions recorded in the audit trail, besides the resolutions.
+const (
+	ModerationClaim       = "claim"
+	ModerationUnhide      = "unhide"
+	ModerationSensitivity = "sensitivity"
+)
+
+// ReportReasons are the categories users file reports under.
+var ReportReasons = []string{
+	"spam",
+	"harassment",
+	"hate",
+	"violence",
+	"sexual",
+	"self_harm",
+	"misinformation",
+	"impersonation",
+	"other",
+}
+
+// maxReportCommentLength caps the free-text part of a report, in runes.
+const maxReportCommentLength = 1000
+
+var (
+	ErrReportNotFound      = errors.New("Report doesn't exist")
+	ErrInvalidReportReason = errors.New("Reason must be one of " + strings.Join(ReportReasons, ", "))
+	ErrReportCommentLength = errors.New("Report comments can be at most 1000 characters")
+	ErrAlreadyReported     = errors.New("User has already reported this and it is still open")
+	ErrSelfReport          = errors.New("Users cannot report themselves or their own chirps")
+	ErrReportClaimed       = errors.New("Report is claimed by another moderator")
+	ErrReportResolved      = errors.New("Report is already resolved")
+	ErrInvalidResolution   = errors.New("Action must be dismiss, hide, warn or suspend")
+	ErrNotChirpReport      = errors.New("Only chirp reports can be resolved by hiding the chirp")
+	ErrChirpNotHidden      = errors.New("Chirp isn't hidden")
+	ErrChirpHidden         = errors.New("Chirp was hidden by a moderator and is kept for appeals")
+	ErrModerateSelf        = errors.New("Moderators cannot act on reports against themselves or their own chirps")
+)
+
+// Resolution is how a moderator settles a report. SuspendFor is how long a
+// suspension lasts.
+type Resolution struct {
+	Action     string
+	Note       string
+	SuspendFor time.Duration
+}
+
+// ReportChirp files a report about a chirp the reporter can see.
+func (db *DB) ReportChirp(reporterID, chirpID int, reason, comment string) (Report, error) {
+	return db.fileReport(reporterID, reason, comment, func(dbStruct *DBStructure) (Report, error) {
+		chirp, ok := dbStruct.Chirps[chirpID]
+		if !ok || !dbStruct.chirpVisibleTo(reporterID, chirp) {
+			return Report{}, ErrChirpNotFound
+		}
+		return Report{TargetType: ReportTargetChirp, ChirpID: chirpID, UserID: chirp.AuthorID}, nil
+	})
+}
+
+// ReportUser files a report about an account.
+func (db *DB) ReportUser(reporterID, userID int, reason, comment string) (Report, error) {
+	return db.fileReport(reporterID, reason, comment, func(dbStruct *DBStructure) (Report, error) {
+		if _, ok := dbStruct.Users[userID]; !ok {
+			return Report{}, ErrUserNotFound
+		}
+		return Report{TargetType: ReportTargetUser, UserID: userID}, nil
+	})
+}
+
+func (db *DB) fileReport(
+	reporterID int,
+	reason, comment string,
+	target func(dbStruct *DBStructure) (Report, error),
+) (Report, error) {
+	if !containsString(ReportReasons, reason) {
+		return Report{}, ErrInvalidReportReason
+	}
+	comment = strings.TrimSpace(comment)
+	if len([]rune(comment)) > maxReportCommentLength {
+		return Report{}, ErrReportCommentLength
+	}
+
+	var report Report
+	err := db.update(func(dbStruct *DBStructure) error {
+		var err error
+		report, err = target(dbStruct)
+		if err != nil {
+			return err
+		}
+		if report.UserID == reporterID {
+			return ErrSelfReport
+		}
+		for _, existing := range dbStruct.Reports {
+			if existing.ReporterID == reporterID && existing.Status != ReportResolved &&
+				existing.TargetType == report.TargetType && existing.ChirpID == report.ChirpID &&
+				existing.UserID == report.UserID {
+				return ErrAlreadyReported
+			}
+		}
+		report.ReporterID = reporterID
+		report.Reason = reason
+		report.Comment = comment
+		report = dbStruct.addReport(report, time.Now().UTC())
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// GetReports returns a page of the reports with the given status, oldest
+// first, and whether more follow. An empty status lists the reports that
+// still need a decision, open or claimed.
+func (db *DB) GetReports(status string, after *Position, limit int) ([]Report, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	reports := make([]Report, 0)
+	for _, report := range dbStruct.Reports {
+		if status == "" && report.Status == ReportResolved {
+			continue
+		}
+		if status != "" && report.Status != status {
+			continue
+		}
+		if after != nil && !after.before(report.Position()) {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Position().before(reports[j].Position())
+	})
+
+	hasMore := len(reports) > limit
+	if hasMore {
+		reports = reports[:limit]
+	}
+	for i, report := range reports {
+		reports[i] = dbStruct.presentReport(report)
+	}
+	return reports, hasMore, nil
+}
+
+func (db *DB) GetReport(id int) (Report, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Report{}, err
+	}
+	report, ok := dbStruct.Reports[id]
+	if !ok {
+		return Report{}, ErrReportNotFound
+	}
+	return dbStruct.presentReport(report), nil
+}
+
+// ClaimReport assigns an open report to moderatorID, so that other
+// moderators leave it to them. Moderators can't claim reports against
+// themselves.
+func (db *DB) ClaimReport(id, moderatorID int) (Report, error) {
+	return db.changeReport(id, moderatorID, func(dbStruct *DBStructure, report *Report, now time.Time) error {
+		report.Status = ReportClaimed
+		report.ClaimedBy = moderatorID
+		dbStruct.logModeration(ModerationAction{
+			ModeratorID: moderatorID,
+			Action:      ModerationClaim,
+			ReportID:    report.ID,
+			ChirpID:     report.ChirpID,
+			UserID:      report.UserID,
+		}, now)
+		return nil
+	})
+}
+
+// ResolveReport settles a report that is open or claimed by moderatorID,
+// carrying out the resolution against the reported chirp or user. Reports
+// against moderatorID are left to other moderators.
+func (db *DB) ResolveReport(id, moderatorID int, resolution Resolution) (Report, error) {
+	switch resolution.Action {
+	case ResolutionDismiss, ResolutionHide, ResolutionWarn, ResolutionSuspend:
+	default:
+		return Report{}, ErrInvalidResolution
+	}
+
+	return db.changeReport(id, moderatorID, func(dbStruct *DBStructure, report *Report, now time.Time) error {
+		switch resolution.Action {
+		case ResolutionHide:
+			if report.TargetType != ReportTargetChirp {
+				return ErrNotChirpReport
+			}
+			chirp, ok := dbStruct.Chirps[report.ChirpID]
+			if !ok {
+				return ErrChirpNotFound
+			}
+			chirp.Hidden = true
+			dbStruct.Chirps[chirp.ID] = chirp
+		case ResolutionWarn:
+			dbStruct.Warnings[report.UserID] = append(dbStruct.Warnings[report.UserID], Warning{
+				ReportID:  report.ID,
+				Reason:    report.Reason,
+				Note:      resolution.Note,
+				CreatedAt: now,
+			})
+		case ResolutionSuspend:
+			_, err := dbStruct.suspend(report.UserID, Suspension{
+				For:    resolution.SuspendFor,
+				Reason: report.Reason,
+			}, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		report.Status = ReportResolved
+		report.ClaimedBy = moderatorID
+		report.Resolution = resolution.Action
+		report.ResolvedBy = moderatorID
+		report.ResolvedAt = &now
+		dbStruct.logModeration(ModerationAction{
+			ModeratorID: moderatorID,
+			Action:      resolution.Action,
+			ReportID:    report.ID,
+			ChirpID:     report.ChirpID,
+			UserID:      report.UserID,
+			Note:        resolution.Note,
+		}, now)
+		return nil
+	})
+}
+
+// UnhideChirp restores a hidden chirp, for instance after an appeal.
+// Moderators can't unhide their own chirps.
+func (db *DB) UnhideChirp(chirpID, moderatorID int, note string) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStruct.Chirps[chirpID]
+		if !ok {
+			return ErrChirpNotFound
+		}
+		if !chirp.Hidden {
+			return ErrChirpNotHidden
+		}
+		if chirp.AuthorID == moderatorID {
+			return ErrModerateSelf
+		}
+		chirp.Hidden = false
+		dbStruct.Chirps[chirpID] = chirp
+		dbStruct.logModeration(ModerationAction{
+			ModeratorID: moderatorID,
+			Action:      ModerationUnhide,
+			ChirpID:     chirpID,
+			UserID:      chirp.AuthorID,
+			Note:        note,
+		}, time.Now().UTC())
+		chirp = dbStruct.presentChirp(moderatorID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// GetModerationLog returns a page of the moderation audit trail, newest
+// first, and whether older entries follow.
+func (db *DB) GetModerationLog(after *Position, limit int) ([]ModerationAction, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	actions := make([]ModerationAction, 0, limit+1)
+	for i := len(dbStruct.ModerationLog) - 1; i >= 0 && len(actions) <= limit; i-- {
+		action := dbStruct.ModerationLog[i]
+		position := Position{CreatedAt: action.CreatedAt, ID: action.ID}
+		if after != nil && !position.before(*after) {
+			continue
+		}
+		actions = append(actions, action)
+	}
+
+	hasMore := len(actions) > limit
+	if hasMore {
+		actions = actions[:limit]
+	}
+	return actions, hasMore, nil
+}
+
+// GetWarnings returns the warnings moderators gave a user, oldest first.
+func (db *DB) GetWarnings(userID int) ([]Warning, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	warnings := make([]Warning, len(dbStruct.Warnings[userID]))
+	copy(warnings, dbStruct.Warnings[userID])
+	return warnings, nil
+}
+
+// Position returns where the report sits in GetReports order.
+func (r Report) Position() Position {
+	return Position{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+// changeReport applies change to a report that moderatorID may act on: one
+// that is open or already claimed by them.
+func (db *DB) changeReport(
+	id, moderatorID int,
+	change func(dbStruct *DBStructure, report *Report, now time.Time) error,
+) (Report, error) {
+	var report Report
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		report, ok = dbStruct.Reports[id]
+		if !ok {
+			return ErrReportNotFound
+		}
+		if report.Status == ReportResolved {
+			return ErrReportResolved
+		}
+		if report.Status == ReportClaimed && report.ClaimedBy != moderatorID {
+			return ErrReportClaimed
+		}
+		if report.UserID == moderatorID {
+			return ErrModerateSelf
+		}
+
+		now := time.Now().UTC()
+		err := change(dbStruct, &report, now)
+		if err != nil {
+			return err
+		}
+		report.UpdatedAt = now
+		dbStruct.Reports[id] = report
+		report = dbStruct.presentReport(report)
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+func (dbStruct *DBStructure) addReport(report Report, now time.Time) Report {
+	report.ID = dbStruct.LastReportID + 1
+	report.Status = ReportOpen
+	report.CreatedAt = now
+	report.UpdatedAt = now
+	dbStruct.Reports[report.ID] = report
+	dbStruct.LastReportID = report.ID
+	return report
+}
+
+func (dbStruct *DBStructure) logModeration(action ModerationAction, now time.Time) {
+	action.ID = len(dbStruct.ModerationLog) + 1
+	action.CreatedAt = now
+	dbStruct.ModerationLog = append(dbStruct.ModerationLog, action)
+}
+
+// presentReport attaches the reported chirp for moderators.
+func (dbStruct DBStructure) presentReport(report Report) Report {
+	report.Chirp = nil
+	if chirp, ok := dbStruct.Chirps[report.ChirpID]; ok && report.ChirpID != 0 {
+		report.Chirp = &chirp
+	}
+	return report
+}
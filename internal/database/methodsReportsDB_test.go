@@ -0,0 +1,195 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFileReport(t *testing.T) {
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	reporter := addTestUser(t, db, "reporter")
+	chirp := addTestChirp(t, db, Chirp{AuthorID: author.ID})
+	private := addTestChirp(t, db, Chirp{AuthorID: author.ID, Visibility: VisibilityPrivate})
+
+	report, err := db.ReportChirp(reporter.ID, chirp.ID, "spam", "  buy now  ")
+	if err != nil {
+		t.Fatalf("ReportChirp: %v", err)
+	}
+	if report.Status != ReportOpen || report.UserID != author.ID || report.Comment != "buy now" {
+		t.Errorf("ReportChirp = %+v, want an open report against the author with a trimmed comment", report)
+	}
+
+	tests := []struct {
+		name    string
+		file    func() (Report, error)
+		wantErr error
+	}{
+		{"duplicate while open", func() (Report, error) {
+			return db.ReportChirp(reporter.ID, chirp.ID, "hate", "")
+		}, ErrAlreadyReported},
+		{"unknown reason", func() (Report, error) {
+			return db.ReportUser(reporter.ID, author.ID, "boring", "")
+		}, ErrInvalidReportReason},
+		{"long comment", func() (Report, error) {
+			return db.ReportUser(reporter.ID, author.ID, "spam", strings.Repeat("a", maxReportCommentLength+1))
+		}, ErrReportCommentLength},
+		{"own chirp", func() (Report, error) {
+			return db.ReportChirp(author.ID, chirp.ID, "spam", "")
+		}, ErrSelfReport},
+		{"themselves", func() (Report, error) {
+			return db.ReportUser(author.ID, author.ID, "spam", "")
+		}, ErrSelfReport},
+		{"chirp the reporter can't see", func() (Report, error) {
+			return db.ReportChirp(reporter.ID, private.ID, "spam", "")
+		}, ErrChirpNotFound},
+		{"missing user", func() (Report, error) {
+			return db.ReportUser(reporter.ID, 999, "spam", "")
+		}, ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.file(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := db.ResolveReport(report.ID, addTestUser(t, db, "moderator").ID, Resolution{Action: ResolutionDismiss}); err != nil {
+		t.Fatalf("ResolveReport: %v", err)
+	}
+	if _, err := db.ReportChirp(reporter.ID, chirp.ID, "spam", ""); err != nil {
+		t.Errorf("ReportChirp after the first report was resolved: %v", err)
+	}
+}
+
+func TestClaimAndResolveReport(t *testing.T) {
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	reporter := addTestUser(t, db, "reporter")
+	moderator := addTestUser(t, db, "moderator")
+	other := addTestUser(t, db, "other")
+
+	report, err := db.ReportUser(reporter.ID, author.ID, "harassment", "")
+	if err != nil {
+		t.Fatalf("ReportUser: %v", err)
+	}
+	claimed, err := db.ClaimReport(report.ID, moderator.ID)
+	if err != nil {
+		t.Fatalf("ClaimReport: %v", err)
+	}
+	if claimed.Status != ReportClaimed || claimed.ClaimedBy != moderator.ID {
+		t.Errorf("ClaimReport = %+v, want it claimed by %d", claimed, moderator.ID)
+	}
+
+	if _, err := db.ClaimReport(report.ID, other.ID); !errors.Is(err, ErrReportClaimed) {
+		t.Errorf("ClaimReport by another moderator error = %v, want ErrReportClaimed", err)
+	}
+	_, err = db.ResolveReport(report.ID, other.ID, Resolution{Action: ResolutionDismiss})
+	if !errors.Is(err, ErrReportClaimed) {
+		t.Errorf("ResolveReport by another moderator error = %v, want ErrReportClaimed", err)
+	}
+	_, err = db.ResolveReport(report.ID, moderator.ID, Resolution{Action: ResolutionHide})
+	if !errors.Is(err, ErrNotChirpReport) {
+		t.Errorf("hiding a user report error = %v, want ErrNotChirpReport", err)
+	}
+	_, err = db.ResolveReport(report.ID, moderator.ID, Resolution{Action: "ignore"})
+	if !errors.Is(err, ErrInvalidResolution) {
+		t.Errorf("unknown resolution error = %v, want ErrInvalidResolution", err)
+	}
+
+	resolved, err := db.ResolveReport(report.ID, moderator.ID, Resolution{Action: ResolutionWarn, Note: "be nice"})
+	if err != nil {
+		t.Fatalf("ResolveReport: %v", err)
+	}
+	if resolved.Status != ReportResolved || resolved.Resolution != ResolutionWarn ||
+		resolved.ResolvedBy != moderator.ID || resolved.ResolvedAt == nil {
+		t.Errorf("ResolveReport = %+v, want it resolved with a warning", resolved)
+	}
+	warnings, err := db.GetWarnings(author.ID)
+	if err != nil {
+		t.Fatalf("GetWarnings: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].ReportID != report.ID || warnings[0].Note != "be nice" {
+		t.Errorf("GetWarnings = %+v, want the warning from report %d", warnings, report.ID)
+	}
+
+	if _, err := db.ClaimReport(report.ID, moderator.ID); !errors.Is(err, ErrReportResolved) {
+		t.Errorf("ClaimReport after resolving error = %v, want ErrReportResolved", err)
+	}
+	_, err = db.ResolveReport(report.ID, moderator.ID, Resolution{Action: ResolutionDismiss})
+	if !errors.Is(err, ErrReportResolved) {
+		t.Errorf("ResolveReport twice error = %v, want ErrReportResolved", err)
+	}
+	if _, err := db.ClaimReport(999, moderator.ID); !errors.Is(err, ErrReportNotFound) {
+		t.Errorf("ClaimReport of a missing report error = %v, want ErrReportNotFound", err)
+	}
+}
+
+func TestModeratorsCantModerateThemselves(t *testing.T) {
+	db := newTestDB(t)
+	moderator := addTestUser(t, db, "moderator")
+	reporter := addTestUser(t, db, "reporter")
+	other := addTestUser(t, db, "other")
+	chirp := addTestChirp(t, db, Chirp{AuthorID: moderator.ID})
+
+	userReport, err := db.ReportUser(reporter.ID, moderator.ID, "spam", "")
+	if err != nil {
+		t.Fatalf("ReportUser: %v", err)
+	}
+	chirpReport, err := db.ReportChirp(reporter.ID, chirp.ID, "spam", "")
+	if err != nil {
+		t.Fatalf("ReportChirp: %v", err)
+	}
+
+	for _, report := range []Report{userReport, chirpReport} {
+		if _, err := db.ClaimReport(report.ID, moderator.ID); !errors.Is(err, ErrModerateSelf) {
+			t.Errorf("ClaimReport(%d) error = %v, want ErrModerateSelf", report.ID, err)
+		}
+		_, err := db.ResolveReport(report.ID, moderator.ID, Resolution{Action: ResolutionDismiss})
+		if !errors.Is(err, ErrModerateSelf) {
+			t.Errorf("ResolveReport(%d) error = %v, want ErrModerateSelf", report.ID, err)
+		}
+	}
+
+	if _, err := db.ResolveReport(chirpReport.ID, other.ID, Resolution{Action: ResolutionHide}); err != nil {
+		t.Fatalf("ResolveReport by another moderator: %v", err)
+	}
+	if _, err := db.UnhideChirp(chirp.ID, moderator.ID, ""); !errors.Is(err, ErrModerateSelf) {
+		t.Errorf("UnhideChirp of their own chirp error = %v, want ErrModerateSelf", err)
+	}
+}
+
+func TestHiddenChirpSurvivesDeleteForAppeal(t *testing.T) {
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	reporter := addTestUser(t, db, "reporter")
+	moderator := addTestUser(t, db, "moderator")
+	chirp := addTestChirp(t, db, Chirp{AuthorID: author.ID})
+
+	report, err := db.ReportChirp(reporter.ID, chirp.ID, "spam", "")
+	if err != nil {
+		t.Fatalf("ReportChirp: %v", err)
+	}
+	_, err = db.ResolveReport(report.ID, moderator.ID, Resolution{Action: ResolutionHide})
+	if err != nil {
+		t.Fatalf("ResolveReport: %v", err)
+	}
+
+	_, err = db.DeleteChirpByID(chirp.ID, author.ID)
+	if !errors.Is(err, ErrChirpHidden) {
+		t.Fatalf("DeleteChirpByID error = %v, want ErrChirpHidden", err)
+	}
+
+	_, err = db.UnhideChirp(chirp.ID, moderator.ID, "appeal upheld")
+	if err != nil {
+		t.Fatalf("UnhideChirp: %v", err)
+	}
+	if _, err := db.GetChirpByID(chirp.ID, reporter.ID); err != nil {
+		t.Errorf("GetChirpByID after unhiding: %v", err)
+	}
+	if _, err := db.DeleteChirpByID(chirp.ID, author.ID); err != nil {
+		t.Errorf("DeleteChirpByID after unhiding: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"time"
+)
+
+// EditChirp replaces the body of one of authorID's chirps and records the
+// new version in the chirp's revision history.
+func (db *DB) EditChirp(id, authorID int, body string) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStruct.Chirps[id]
+		if !ok {
+			return ErrChirpNotFound
+		}
+		if chirp.AuthorID != authorID {
+			return ErrNotChirpAuthor
+		}
+
+		now := time.Now().UTC()
+		if len(dbStruct.Revisions[id]) == 0 {
+			dbStruct.Revisions[id] = []ChirpRevision{{Body: chirp.Body, CreatedAt: chirp.CreatedAt}}
+		}
+		dbStruct.Revisions[id] = append(dbStruct.Revisions[id], ChirpRevision{
+			Body:      body,
+			CreatedAt: now,
+		})
+
+		dbStruct.unindexChirp(chirp)
+		chirp.Body = body
+		chirp.Edited = true
+		chirp.UpdatedAt = now
+		chirp = dbStruct.indexChirp(chirp)
+		dbStruct.Chirps[id] = chirp
+		chirp = dbStruct.presentChirp(authorID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	db.indexForSearch(chirp)
+	return chirp, nil
+}
+
+// GetChirpHistory returns every version of a chirp, oldest first. A chirp
+// that was never edited has a single revision.
+func (db *DB) GetChirpHistory(id, viewerID int) ([]ChirpRevision, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	chirp, ok := dbStruct.Chirps[id]
+	if !ok || !dbStruct.chirpVisibleTo(viewerID, chirp) {
+		return nil, ErrChirpNotFound
+	}
+
+	revisions := dbStruct.Revisions[id]
+	if len(revisions) == 0 {
+		revisions = []ChirpRevision{{Body: chirp.Body, CreatedAt: chirp.CreatedAt}}
+	}
+	return revisions, nil
+}
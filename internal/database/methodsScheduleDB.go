@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	ChirpPublished = "published"
+	ChirpDraft     = "draft"
+	ChirpScheduled = "scheduled"
+)
+
+var ErrChirpNotPending = errors.New("Chirp is not a draft or scheduled chirp")
+
+// Published reports whether the chirp can be seen by anyone but its author.
+func (c Chirp) Published() bool {
+	return c.Status == ChirpPublished
+}
+
+// GetPendingChirps returns the author's chirps with the given status, either
+// ChirpDraft or ChirpScheduled. Scheduled chirps come in the order they are
+// due and drafts most recently saved first.
+func (db *DB) GetPendingChirps(authorID int, status string) ([]Chirp, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	chirps := make([]Chirp, 0)
+	for _, chirp := range dbStruct.Chirps {
+		if chirp.AuthorID == authorID && chirp.Status == status {
+			chirps = append(chirps, chirp)
+		}
+	}
+	sort.Slice(chirps, func(i, j int) bool {
+		a, b := chirps[i], chirps[j]
+		if status == ChirpScheduled && !a.PublishAt.Equal(*b.PublishAt) {
+			return a.PublishAt.Before(*b.PublishAt)
+		}
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		return a.ID < b.ID
+	})
+	return dbStruct.presentChirps(authorID, chirps), nil
+}
+
+// GetPendingChirp returns one of the author's drafts or scheduled chirps.
+func (db *DB) GetPendingChirp(id, authorID int) (Chirp, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Chirp{}, err
+	}
+	chirp, err := dbStruct.pendingChirp(id, authorID)
+	if err != nil {
+		return Chirp{}, err
+	}
+	return dbStruct.presentChirp(authorID, chirp), nil
+}
+
+// UpdatePendingChirp replaces the body of a draft or scheduled chirp and
+// reschedules it for publishAt, or turns it into a draft when publishAt is
+// nil.
+func (db *DB) UpdatePendingChirp(id, authorID int, body string, publishAt *time.Time) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var err error
+		chirp, err = dbStruct.pendingChirp(id, authorID)
+		if err != nil {
+			return err
+		}
+
+		dbStruct.unindexChirp(chirp)
+		chirp.Body = body
+		chirp.Status = ChirpDraft
+		chirp.PublishAt = nil
+		if publishAt != nil {
+			due := publishAt.UTC()
+			chirp.Status = ChirpScheduled
+			chirp.PublishAt = &due
+		}
+		chirp.UpdatedAt = time.Now().UTC()
+		chirp = dbStruct.indexChirp(chirp)
+		dbStruct.Chirps[id] = chirp
+		chirp = dbStruct.presentChirp(authorID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	db.indexForSearch(chirp)
+	return chirp, nil
+}
+
+// PublishChirp publishes one of the author's drafts or scheduled chirps
+// straight away. Suspended authors get ErrUserSuspended.
+func (db *DB) PublishChirp(id, authorID int) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var err error
+		chirp, err = dbStruct.pendingChirp(id, authorID)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if dbStruct.Users[authorID].Suspended(now) {
+			return ErrUserSuspended
+		}
+		chirp = dbStruct.publish(chirp, now)
+		chirp = dbStruct.presentChirp(authorID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// PublishDueChirps publishes every scheduled chirp due at or before now. A
+// chirp's status changes in the same transaction that publishes it, so no
+// chirp is ever published twice. Chirps by suspended authors are held back
+// until the suspension ends or is lifted.
+func (db *DB) PublishDueChirps(now time.Time) ([]Chirp, error) {
+	published := make([]Chirp, 0)
+	err := db.update(func(dbStruct *DBStructure) error {
+		due := make([]Chirp, 0)
+		for _, chirp := range dbStruct.Chirps {
+			if dbStruct.scheduledFor(chirp, now) && !chirp.PublishAt.After(now) {
+				due = append(due, chirp)
+			}
+		}
+		sort.Slice(due, func(i, j int) bool {
+			if !due[i].PublishAt.Equal(*due[j].PublishAt) {
+				return due[i].PublishAt.Before(*due[j].PublishAt)
+			}
+			return due[i].ID < due[j].ID
+		})
+		for _, chirp := range due {
+			published = append(published, dbStruct.publish(chirp, now.UTC()))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// NextDueAt returns when the next scheduled chirp is due to be published,
+// poll is due to close or suspension is due to end, if there is any. Chirps
+// held back by a suspension are due again once it ends.
+func (db *DB) NextDueAt() (time.Time, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	now := time.Now()
+	var next time.Time
+	found := false
+	consider := func(due *time.Time) {
+		if due != nil && (!found || due.Before(next)) {
+			next = *due
+			found = true
+		}
+	}
+	for _, chirp := range dbStruct.Chirps {
+		if dbStruct.scheduledFor(chirp, now) {
+			consider(chirp.PublishAt)
+		}
+		if chirp.Poll != nil && !chirp.Poll.Closed {
+			consider(chirp.Poll.ExpiresAt)
+		}
+	}
+	for _, user := range dbStruct.Users {
+		if !user.Banned {
+			consider(user.SuspendedUntil)
+		}
+	}
+	return next, found, nil
+}
+
+// scheduledFor reports whether chirp is scheduled and its author is free to
+// have it published at now.
+func (dbStruct DBStructure) scheduledFor(chirp Chirp, now time.Time) bool {
+	return chirp.Status == ChirpScheduled && !dbStruct.Users[chirp.AuthorID].Suspended(now)
+}
+
+func (dbStruct DBStructure) pendingChirp(id, authorID int) (Chirp, error) {
+	// Other users can't tell that someone else's pending chirp exists.
+	chirp, ok := dbStruct.Chirps[id]
+	if !ok || chirp.AuthorID != authorID {
+		return Chirp{}, ErrChirpNotFound
+	}
+	if chirp.Published() {
+		return Chirp{}, ErrChirpNotPending
+	}
+	return chirp, nil
+}
+
+// publish makes a pending chirp visible as of now. It takes its place in
+// feeds from the time it was published rather than when it was written.
+func (dbStruct *DBStructure) publish(chirp Chirp, now time.Time) Chirp {
+	chirp.Status = ChirpPublished
+	chirp.PublishAt = nil
+	chirp.CreatedAt = now
+	chirp.UpdatedAt = now
+	chirp.startPoll(now)
+	dbStruct.countReferences(chirp, 1)
+	dbStruct.Chirps[chirp.ID] = chirp
+	return chirp
+}
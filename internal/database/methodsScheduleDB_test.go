@@ -0,0 +1,55 @@
+package database
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublishDueChirpsPublishesOnce(t *testing.T) {
+	const workers = 8
+	db := newTestDB(t)
+	author := addTestUser(t, db, "author")
+	due := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+	scheduled := addTestChirp(t, db, Chirp{AuthorID: author.ID, Status: ChirpScheduled, PublishAt: &due})
+	addTestChirp(t, db, Chirp{AuthorID: author.ID, Status: ChirpScheduled, PublishAt: &later})
+
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	published := make([]Chirp, 0)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chirps, err := db.PublishDueChirps(time.Now())
+			if err != nil {
+				t.Errorf("PublishDueChirps: %v", err)
+				return
+			}
+			mux.Lock()
+			published = append(published, chirps...)
+			mux.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(published) != 1 || published[0].ID != scheduled.ID {
+		t.Fatalf("published %v across %d calls, want chirp %d once", published, workers, scheduled.ID)
+	}
+	again, err := db.PublishDueChirps(time.Now())
+	if err != nil {
+		t.Fatalf("PublishDueChirps: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("second PublishDueChirps published %v, want nothing", again)
+	}
+
+	chirp, err := db.GetChirpByID(scheduled.ID, 0)
+	if err != nil {
+		t.Fatalf("GetChirpByID: %v", err)
+	}
+	if chirp.Status != ChirpPublished || chirp.PublishAt != nil {
+		t.Errorf("published chirp has status %q and publish_at %v", chirp.Status, chirp.PublishAt)
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/AxterDoesCode/webserver/internal/search"
+)
+
+// SearchQuery describes a page of full-text search results.
+type SearchQuery struct {
+	// Text is the search string, as understood by search.ParseQuery.
+	Text     string
+	ViewerID int
+	// Newest orders results newest first instead of by relevance.
+	Newest bool
+	// Offset skips that many results when ordering by relevance, and After
+	// is the position of the last chirp of the previous page when ordering
+	// by newest.
+	Offset int
+	After  *Position
+	Limit  int
+}
+
+type searchHit struct {
+	chirp Chirp
+	score float64
+}
+
+// SearchChirps returns the chirps matching query in order, and whether more
+// results follow the returned page. A query with only from: or # operators
+// matches every chirp they allow.
+func (db *DB) SearchChirps(query SearchQuery) ([]Chirp, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	parsed := search.ParseQuery(query.Text)
+	authorID := 0
+	if parsed.From != "" {
+		author, ok := dbStruct.userByHandle(parsed.From)
+		if !ok {
+			return make([]Chirp, 0), false, nil
+		}
+		authorID = author.ID
+	}
+
+	hits := make([]searchHit, 0)
+	addHit := func(chirp Chirp, score float64) {
+		if authorID != 0 && chirp.AuthorID != authorID {
+			return
+		}
+		if !chirpHasTags(chirp, parsed.Tags) || !dbStruct.chirpListedTo(query.ViewerID, chirp) {
+			return
+		}
+		if query.Newest && query.After != nil && !chirp.Position().before(*query.After) {
+			return
+		}
+		hits = append(hits, searchHit{chirp: chirp, score: score})
+	}
+	if parsed.HasText() {
+		for _, result := range db.search.Search(parsed) {
+			if chirp, ok := dbStruct.Chirps[result.ID]; ok {
+				addHit(chirp, result.Score)
+			}
+		}
+	} else {
+		for _, chirp := range dbStruct.Chirps {
+			addHit(chirp, 0)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if !query.Newest && hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[j].chirp.Position().before(hits[i].chirp.Position())
+	})
+
+	if !query.Newest {
+		if query.Offset >= len(hits) {
+			hits = hits[:0]
+		} else {
+			hits = hits[query.Offset:]
+		}
+	}
+	hasMore := query.Limit > 0 && len(hits) > query.Limit
+	if hasMore {
+		hits = hits[:query.Limit]
+	}
+
+	chirps := make([]Chirp, len(hits))
+	for i, hit := range hits {
+		chirps[i] = hit.chirp
+	}
+	return dbStruct.presentChirps(query.ViewerID, chirps), hasMore, nil
+}
+
+// RebuildSearchIndex indexes every chirp in the store from scratch and saves
+// the result, replacing the current index.
+func (db *DB) RebuildSearchIndex() error {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	idx := search.NewIndex()
+	for _, chirp := range dbStruct.Chirps {
+		idx.Add(chirp.ID, chirp.Body)
+	}
+	err = idx.Save(db.searchPath)
+	if err != nil {
+		return err
+	}
+	db.search = idx
+	return nil
+}
+
+// openSearchIndex loads the saved search index, building it from the store
+// when there is none, it is outdated or it has fallen out of step with the
+// store.
+func (db *DB) openSearchIndex() error {
+	idx, err := search.Load(db.searchPath)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, search.ErrOutdated) {
+		return db.RebuildSearchIndex()
+	}
+	if err != nil {
+		return err
+	}
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	if idx.Len() != len(dbStruct.Chirps) {
+		return db.RebuildSearchIndex()
+	}
+	db.search = idx
+	return nil
+}
+
+// indexForSearch updates the search index after a chirp was created or
+// edited. The store is already committed by then, so a failed save is only
+// logged; the index is rebuilt the next time it is opened.
+func (db *DB) indexForSearch(chirp Chirp) {
+	db.search.Add(chirp.ID, chirp.Body)
+	db.saveSearchIndex()
+}
+
+func (db *DB) unindexForSearch(chirpID int) {
+	db.search.Remove(chirpID)
+	db.saveSearchIndex()
+}
+
+func (db *DB) saveSearchIndex() {
+	err := db.search.Save(db.searchPath)
+	if err != nil {
+		log.Printf("Saving search index: %s", err)
+		os.Remove(db.searchPath)
+	}
+}
+
+func chirpHasTags(chirp Chirp, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, entity := range chirp.Entities {
+			if entity.Type == EntityHashtag && NormalizeTag(entity.Text) == NormalizeTag(tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	SensitiveCollapse = "collapse"
+	SensitiveExpand   = "expand"
+	SensitiveFilter   = "filter"
+)
+
+var ErrInvalidPreference = errors.New("sensitive_content must be collapse, expand or filter")
+
+// SensitivityChanges describes a moderator's change to a chirp's content
+// warning and sensitive flag. Nil fields are left alone, and an empty
+// ContentWarning removes the warning.
+type SensitivityChanges struct {
+	ContentWarning *string
+	Sensitive      *bool
+}
+
+// GetPreferences returns the user's viewing preferences, with defaults
+// filled in.
+func (db *DB) GetPreferences(userID int) (Preferences, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Preferences{}, err
+	}
+	if _, ok := dbStruct.Users[userID]; !ok {
+		return Preferences{}, ErrUserNotFound
+	}
+	return Preferences{SensitiveContent: dbStruct.sensitivePreference(userID)}, nil
+}
+
+func (db *DB) UpdatePreferences(userID int, preferences Preferences) (Preferences, error) {
+	switch preferences.SensitiveContent {
+	case SensitiveCollapse, SensitiveExpand, SensitiveFilter:
+	default:
+		return Preferences{}, ErrInvalidPreference
+	}
+	err := db.update(func(dbStruct *DBStructure) error {
+		if _, ok := dbStruct.Users[userID]; !ok {
+			return ErrUserNotFound
+		}
+		dbStruct.Preferences[userID] = preferences
+		return nil
+	})
+	if err != nil {
+		return Preferences{}, err
+	}
+	return preferences, nil
+}
+
+// SetChirpSensitivity applies a moderator's changes to a chirp's content
+// warning and sensitive flag, records it in the moderation audit trail and
+// returns the chirp as moderatorID sees it.
+func (db *DB) SetChirpSensitivity(chirpID, moderatorID int, changes SensitivityChanges) (Chirp, error) {
+	var chirp Chirp
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStruct.Chirps[chirpID]
+		if !ok {
+			return ErrChirpNotFound
+		}
+		if changes.ContentWarning != nil {
+			chirp.ContentWarning = *changes.ContentWarning
+		}
+		if changes.Sensitive != nil {
+			chirp.Sensitive = *changes.Sensitive
+		}
+		dbStruct.Chirps[chirpID] = chirp
+		dbStruct.logModeration(ModerationAction{
+			ModeratorID: moderatorID,
+			Action:      ModerationSensitivity,
+			ChirpID:     chirpID,
+			UserID:      chirp.AuthorID,
+			Note:        fmt.Sprintf("content_warning=%q sensitive=%t", chirp.ContentWarning, chirp.Sensitive),
+		}, time.Now().UTC())
+		chirp = dbStruct.presentChirp(moderatorID, chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// flaggedSensitive reports whether the chirp has a content warning or
+// sensitive media.
+func (chirp Chirp) flaggedSensitive() bool {
+	return chirp.ContentWarning != "" || chirp.Sensitive
+}
+
+// sensitivePreference returns how viewerID wants sensitive chirps shown.
+// Anonymous viewers and users who haven't chosen get them collapsed.
+func (dbStruct DBStructure) sensitivePreference(viewerID int) string {
+	if preferences, ok := dbStruct.Preferences[viewerID]; ok && preferences.SensitiveContent != "" {
+		return preferences.SensitiveContent
+	}
+	return SensitiveCollapse
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrSessionRevoked        = errors.New("Session has been revoked")
+	ErrPasswordResetRequired = errors.New("Password must be changed before continuing")
+	ErrImpersonateAdmin      = errors.New("Admins cannot be impersonated")
+)
+
+// Active reports whether the session's tokens still work at now.
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// CreateSession stores a new session for session.UserID. The caller picks
+// the ID, the client details and when it expires.
+func (db *DB) CreateSession(session Session) (Session, error) {
+	err := db.update(func(dbStruct *DBStructure) error {
+		user, ok := dbStruct.Users[session.UserID]
+		if !ok {
+			return ErrUserNotFound
+		}
+		if session.ImpersonatorID != 0 && user.Role == RoleAdmin {
+			return ErrImpersonateAdmin
+		}
+		now := time.Now().UTC()
+		session.CreatedAt = now
+		session.LastUsedAt = now
+		dbStruct.Sessions[session.ID] = session
+		return nil
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// TouchSession records that userID used a session to refresh their tokens.
+// It fails with ErrSessionRevoked once the session has ended or if it
+// belongs to someone else.
+func (db *DB) TouchSession(id string, userID int) (Session, error) {
+	var session Session
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		session, ok = dbStruct.Sessions[id]
+		now := time.Now().UTC()
+		if !ok || session.UserID != userID || !session.Active(now) {
+			return ErrSessionRevoked
+		}
+		session.LastUsedAt = now
+		dbStruct.Sessions[id] = session
+		return nil
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+func (db *DB) RevokeSession(id string) error {
+	return db.update(func(dbStruct *DBStructure) error {
+		session, ok := dbStruct.Sessions[id]
+		if !ok {
+			return ErrSessionRevoked
+		}
+		if session.RevokedAt == nil {
+			now := time.Now().UTC()
+			session.RevokedAt = &now
+			dbStruct.Sessions[id] = session
+		}
+		return nil
+	})
+}
+
+// RevokeUserSessions ends every active session of a user and returns how
+// many there were.
+func (db *DB) RevokeUserSessions(userID int) (int, error) {
+	revoked := 0
+	err := db.update(func(dbStruct *DBStructure) error {
+		if _, ok := dbStruct.Users[userID]; !ok {
+			return ErrUserNotFound
+		}
+		revoked = dbStruct.revokeSessions(userID, time.Now().UTC())
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+// GetUserSessions returns a user's sessions, newest first.
+func (db *DB) GetUserSessions(userID int) ([]Session, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := dbStruct.Users[userID]; !ok {
+		return nil, ErrUserNotFound
+	}
+
+	sessions := make([]Session, 0)
+	for _, session := range dbStruct.Sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// ValidateAccess checks that an access token for userID may still be used:
+// its session belongs to the user and hasn't ended, and the user isn't
+// required to reset their password. Tokens issued before sessions existed
+// carry no session ID and are rejected.
+func (db *DB) ValidateAccess(userID int, sessionID string) error {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	session, ok := dbStruct.Sessions[sessionID]
+	if !ok || session.UserID != userID || !session.Active(time.Now()) {
+		return ErrSessionRevoked
+	}
+	if dbStruct.Users[userID].PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// RequirePasswordReset makes a user change their password before doing
+// anything else, ending their sessions so that they have to log in again.
+func (db *DB) RequirePasswordReset(userID int) (User, error) {
+	var user User
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		user, ok = dbStruct.Users[userID]
+		if !ok {
+			return ErrUserNotFound
+		}
+		now := time.Now().UTC()
+		user.PasswordResetRequired = true
+		user.UpdatedAt = now
+		dbStruct.Users[userID] = user
+		dbStruct.revokeSessions(userID, now)
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user.withoutPassword(), nil
+}
+
+func (dbStruct *DBStructure) revokeSessions(userID int, now time.Time) int {
+	revoked := 0
+	for id, session := range dbStruct.Sessions {
+		if session.UserID != userID || session.RevokedAt != nil {
+			continue
+		}
+		session.RevokedAt = &now
+		dbStruct.Sessions[id] = session
+		revoked++
+	}
+	return revoked
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// Moderation actions on accounts, besides ResolutionSuspend.
+const (
+	ModerationBan  = "ban"
+	ModerationLift = "lift"
+)
+
+var (
+	ErrUserNotSuspended = errors.New("User isn't suspended")
+	ErrSuspendAdmin     = errors.New("Admins cannot be suspended")
+	ErrUserSuspended    = errors.New("Account is suspended")
+)
+
+// Suspension describes how an account is suspended. A zero For bans the
+// account until the ban is lifted. HideChirps, when set, hides or shows the
+// user's chirps for as long as the suspension lasts; left nil, a suspension
+// already in force keeps hiding them or not.
+type Suspension struct {
+	For        time.Duration
+	Reason     string
+	HideChirps *bool
+}
+
+// Suspended reports whether the user is suspended or banned at now.
+func (u User) Suspended(now time.Time) bool {
+	return u.Banned || (u.SuspendedUntil != nil && now.Before(*u.SuspendedUntil))
+}
+
+// SuspendUser suspends or bans an account and ends its sessions, so that
+// tokens it already holds stop working straight away. A suspension already
+// in force is only ever extended, never shortened; LiftSuspension is for
+// ending one early.
+func (db *DB) SuspendUser(userID, moderatorID int, suspension Suspension) (User, error) {
+	var user User
+	err := db.update(func(dbStruct *DBStructure) error {
+		now := time.Now().UTC()
+		var err error
+		user, err = dbStruct.suspend(userID, suspension, now)
+		if err != nil {
+			return err
+		}
+
+		action := ResolutionSuspend
+		if user.Banned {
+			action = ModerationBan
+		}
+		dbStruct.logModeration(ModerationAction{
+			ModeratorID: moderatorID,
+			Action:      action,
+			UserID:      userID,
+			Note:        suspension.Reason,
+		}, now)
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user.withoutPassword(), nil
+}
+
+// LiftSuspension ends an account's suspension or ban early.
+func (db *DB) LiftSuspension(userID, moderatorID int, note string) (User, error) {
+	var user User
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		user, ok = dbStruct.Users[userID]
+		if !ok {
+			return ErrUserNotFound
+		}
+		now := time.Now().UTC()
+		if !user.Suspended(now) {
+			return ErrUserNotSuspended
+		}
+
+		user = dbStruct.lift(user, now)
+		dbStruct.logModeration(ModerationAction{
+			ModeratorID: moderatorID,
+			Action:      ModerationLift,
+			UserID:      userID,
+			Note:        note,
+		}, now)
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user.withoutPassword(), nil
+}
+
+// LiftExpiredSuspensions lifts every suspension that has run out by now,
+// bringing back chirps they hid. The lifts are logged without a moderator.
+func (db *DB) LiftExpiredSuspensions(now time.Time) ([]User, error) {
+	lifted := make([]User, 0)
+	err := db.update(func(dbStruct *DBStructure) error {
+		for _, user := range dbStruct.Users {
+			if user.Banned || user.SuspendedUntil == nil || user.SuspendedUntil.After(now) {
+				continue
+			}
+			user = dbStruct.lift(user, now)
+			dbStruct.logModeration(ModerationAction{
+				Action: ModerationLift,
+				UserID: user.ID,
+				Note:   "Suspension ended",
+			}, now)
+			lifted = append(lifted, user.withoutPassword())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lifted, nil
+}
+
+func (dbStruct *DBStructure) suspend(userID int, suspension Suspension, now time.Time) (User, error) {
+	user, ok := dbStruct.Users[userID]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	if user.Role == RoleAdmin {
+		return User{}, ErrSuspendAdmin
+	}
+
+	active := user.Suspended(now)
+	if !active {
+		user.Banned = false
+		user.SuspendedUntil = nil
+		user.ChirpsHidden = false
+	}
+	switch {
+	case suspension.For == 0:
+		if !user.Banned {
+			user.SuspensionReason = suspension.Reason
+		}
+		user.Banned = true
+		user.SuspendedUntil = nil
+	case user.Banned:
+		// A ban outlasts any suspension.
+	default:
+		until := now.Add(suspension.For)
+		if user.SuspendedUntil == nil || user.SuspendedUntil.Before(until) {
+			user.SuspendedUntil = &until
+			user.SuspensionReason = suspension.Reason
+		}
+	}
+	if suspension.HideChirps != nil {
+		user.ChirpsHidden = *suspension.HideChirps
+	}
+	user.UpdatedAt = now
+	dbStruct.Users[userID] = user
+	dbStruct.revokeSessions(userID, now)
+	return user, nil
+}
+
+func (dbStruct *DBStructure) lift(user User, now time.Time) User {
+	user.Banned = false
+	user.SuspendedUntil = nil
+	user.SuspensionReason = ""
+	user.ChirpsHidden = false
+	user.UpdatedAt = now
+	dbStruct.Users[user.ID] = user
+	return user
+}
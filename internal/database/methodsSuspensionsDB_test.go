@@ -0,0 +1,227 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSuspensionsOnlyGrow(t *testing.T) {
+	const day = 24 * time.Hour
+	hide := true
+
+	tests := []struct {
+		name       string
+		first      Suspension
+		second     time.Duration
+		wantBanned bool
+		wantFor    time.Duration
+		wantHidden bool
+		wantReason string
+	}{
+		{
+			name:       "a report doesn't turn a ban into a suspension",
+			first:      Suspension{Reason: "ban", HideChirps: &hide},
+			second:     day,
+			wantBanned: true,
+			wantHidden: true,
+			wantReason: "ban",
+		},
+		{
+			name:       "a shorter suspension doesn't shorten a longer one",
+			first:      Suspension{For: 30 * day, Reason: "long", HideChirps: &hide},
+			second:     day,
+			wantFor:    30 * day,
+			wantHidden: true,
+			wantReason: "long",
+		},
+		{
+			name:       "a longer suspension extends a shorter one",
+			first:      Suspension{For: day, Reason: "short", HideChirps: &hide},
+			second:     30 * day,
+			wantFor:    30 * day,
+			wantHidden: true,
+			wantReason: "spam",
+		},
+		{
+			name:       "a report bans on top of a suspension",
+			first:      Suspension{For: day, Reason: "short"},
+			second:     0,
+			wantBanned: true,
+			wantReason: "spam",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			admin := addTestUser(t, db, "admin")
+			moderator := addTestUser(t, db, "moderator")
+			reporter := addTestUser(t, db, "reporter")
+			user := addTestUser(t, db, "user")
+
+			before := time.Now().UTC()
+			if _, err := db.SuspendUser(user.ID, admin.ID, tt.first); err != nil {
+				t.Fatalf("SuspendUser: %v", err)
+			}
+			report, err := db.ReportUser(reporter.ID, user.ID, "spam", "")
+			if err != nil {
+				t.Fatalf("ReportUser: %v", err)
+			}
+			_, err = db.ResolveReport(report.ID, moderator.ID, Resolution{
+				Action:     ResolutionSuspend,
+				SuspendFor: tt.second,
+			})
+			if err != nil {
+				t.Fatalf("ResolveReport: %v", err)
+			}
+
+			got, err := db.GetUser(user.ID)
+			if err != nil {
+				t.Fatalf("GetUser: %v", err)
+			}
+			if got.Banned != tt.wantBanned {
+				t.Errorf("Banned = %v, want %v", got.Banned, tt.wantBanned)
+			}
+			if tt.wantBanned && got.SuspendedUntil != nil {
+				t.Errorf("SuspendedUntil = %v, want nil for a ban", got.SuspendedUntil)
+			}
+			if !tt.wantBanned {
+				if got.SuspendedUntil == nil {
+					t.Fatal("SuspendedUntil = nil")
+				}
+				want := before.Add(tt.wantFor)
+				if got.SuspendedUntil.Before(want) || got.SuspendedUntil.After(want.Add(time.Minute)) {
+					t.Errorf("SuspendedUntil = %v, want about %v", got.SuspendedUntil, want)
+				}
+			}
+			if got.ChirpsHidden != tt.wantHidden {
+				t.Errorf("ChirpsHidden = %v, want %v", got.ChirpsHidden, tt.wantHidden)
+			}
+			if got.SuspensionReason != tt.wantReason {
+				t.Errorf("SuspensionReason = %q, want %q", got.SuspensionReason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSuspensionEndsSessions(t *testing.T) {
+	suspend := map[string]func(db *DB, userID, moderatorID int) error{
+		"admin suspension": func(db *DB, userID, moderatorID int) error {
+			_, err := db.SuspendUser(userID, moderatorID, Suspension{For: time.Hour})
+			return err
+		},
+		"report resolution": func(db *DB, userID, moderatorID int) error {
+			reporter := addTestUser(t, db, "reporter")
+			report, err := db.ReportUser(reporter.ID, userID, "spam", "")
+			if err != nil {
+				return err
+			}
+			_, err = db.ResolveReport(report.ID, moderatorID, Resolution{
+				Action:     ResolutionSuspend,
+				SuspendFor: time.Hour,
+			})
+			return err
+		},
+	}
+	for name, suspend := range suspend {
+		t.Run(name, func(t *testing.T) {
+			db := newTestDB(t)
+			moderator := addTestUser(t, db, "moderator")
+			user := addTestUser(t, db, "user")
+			session, err := db.CreateSession(Session{
+				ID:        "session",
+				UserID:    user.ID,
+				ExpiresAt: time.Now().Add(time.Hour),
+			})
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			if err := db.ValidateAccess(user.ID, session.ID); err != nil {
+				t.Fatalf("ValidateAccess before suspending: %v", err)
+			}
+
+			if err := suspend(db, user.ID, moderator.ID); err != nil {
+				t.Fatalf("suspending: %v", err)
+			}
+			if err := db.ValidateAccess(user.ID, session.ID); !errors.Is(err, ErrSessionRevoked) {
+				t.Errorf("ValidateAccess error = %v, want ErrSessionRevoked", err)
+			}
+		})
+	}
+}
+
+func TestSuspendedAuthorsDontPublish(t *testing.T) {
+	db := newTestDB(t)
+	admin := addTestUser(t, db, "admin")
+	author := addTestUser(t, db, "author")
+	draft := addTestChirp(t, db, Chirp{AuthorID: author.ID, Status: ChirpDraft})
+	due := time.Now().Add(-time.Minute)
+	scheduled := addTestChirp(t, db, Chirp{AuthorID: author.ID, Status: ChirpScheduled, PublishAt: &due})
+
+	if _, err := db.SuspendUser(author.ID, admin.ID, Suspension{For: time.Hour}); err != nil {
+		t.Fatalf("SuspendUser: %v", err)
+	}
+	if _, err := db.PublishChirp(draft.ID, author.ID); !errors.Is(err, ErrUserSuspended) {
+		t.Errorf("PublishChirp error = %v, want ErrUserSuspended", err)
+	}
+	published, err := db.PublishDueChirps(time.Now())
+	if err != nil {
+		t.Fatalf("PublishDueChirps: %v", err)
+	}
+	if len(published) != 0 {
+		t.Errorf("PublishDueChirps published %d chirps while the author is suspended", len(published))
+	}
+	next, ok, err := db.NextDueAt()
+	if err != nil {
+		t.Fatalf("NextDueAt: %v", err)
+	}
+	if !ok || next.Before(time.Now()) {
+		t.Errorf("NextDueAt = %v, %v, want the end of the suspension", next, ok)
+	}
+
+	if _, err := db.LiftSuspension(author.ID, admin.ID, ""); err != nil {
+		t.Fatalf("LiftSuspension: %v", err)
+	}
+	published, err = db.PublishDueChirps(time.Now())
+	if err != nil {
+		t.Fatalf("PublishDueChirps: %v", err)
+	}
+	if len(published) != 1 || published[0].ID != scheduled.ID {
+		t.Errorf("PublishDueChirps after lifting = %v, want chirp %d", published, scheduled.ID)
+	}
+}
+
+func TestHiddenChirpsReturnWhenSuspensionEnds(t *testing.T) {
+	db := newTestDB(t)
+	admin := addTestUser(t, db, "admin")
+	author := addTestUser(t, db, "author")
+	viewer := addTestUser(t, db, "viewer")
+	chirp := addTestChirp(t, db, Chirp{AuthorID: author.ID})
+
+	hide := true
+	_, err := db.SuspendUser(author.ID, admin.ID, Suspension{For: time.Hour, HideChirps: &hide})
+	if err != nil {
+		t.Fatalf("SuspendUser: %v", err)
+	}
+	if _, err := db.GetChirpByID(chirp.ID, viewer.ID); !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("GetChirpByID while suspended error = %v, want ErrChirpNotFound", err)
+	}
+
+	lifted, err := db.LiftExpiredSuspensions(time.Now())
+	if err != nil {
+		t.Fatalf("LiftExpiredSuspensions: %v", err)
+	}
+	if len(lifted) != 0 {
+		t.Errorf("LiftExpiredSuspensions lifted %d suspensions before they ended", len(lifted))
+	}
+	lifted, err = db.LiftExpiredSuspensions(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("LiftExpiredSuspensions: %v", err)
+	}
+	if len(lifted) != 1 || lifted[0].ID != author.ID {
+		t.Errorf("LiftExpiredSuspensions = %v, want user %d", lifted, author.ID)
+	}
+	if _, err := db.GetChirpByID(chirp.ID, viewer.ID); err != nil {
+		t.Errorf("GetChirpByID after the suspension ended: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"sort"
+)
+
+// maxThreadDepth bounds how many levels of replies are nested under each
+// direct reply in a thread.
+const maxThreadDepth = 5
+
+// ThreadNode is a chirp in a thread together with the replies to it. Chirps
+// that were deleted or that the viewer can't see are kept as Unavailable
+// placeholders carrying only their ID, as long as there is something
+// visible below them.
+type ThreadNode struct {
+	ID int `json:"id"`
+	*Chirp
+	Unavailable bool         `json:"unavailable,omitempty"`
+	Replies     []ThreadNode `json:"replies,omitempty"`
+	position    Position
+}
+
+type Thread struct {
+	// Ancestors runs from the thread root down to the chirp's parent.
+	Ancestors []ThreadNode `json:"ancestors"`
+	Chirp     Chirp        `json:"chirp"`
+	// Replies holds one page of direct replies, oldest first, each with its
+	// own replies nested below it.
+	Replies []ThreadNode `json:"replies"`
+}
+
+// GetThread returns the conversation around a chirp with one page of its
+// direct replies, and whether more direct replies follow.
+func (db *DB) GetThread(id, viewerID int, after *Position, limit int) (Thread, bool, error) {
+	dbStruct, err := db.loadDB()
+	if err != nil {
+		return Thread{}, false, err
+	}
+
+	chirp, ok := dbStruct.Chirps[id]
+	if !ok || !dbStruct.chirpVisibleTo(viewerID, chirp) {
+		return Thread{}, false, ErrChirpNotFound
+	}
+
+	thread := Thread{
+		Ancestors: make([]ThreadNode, 0),
+		Chirp:     dbStruct.presentChirp(viewerID, chirp),
+		Replies:   make([]ThreadNode, 0),
+	}
+
+	for parentID := chirp.InReplyTo; parentID != 0; {
+		parent, ok := dbStruct.Chirps[parentID]
+		if !ok || !dbStruct.chirpVisibleTo(viewerID, parent) {
+			thread.Ancestors = append(thread.Ancestors, ThreadNode{
+				ID:          parentID,
+				Unavailable: true,
+			})
+			parentID = dbStruct.parentOf(parentID)
+			continue
+		}
+		parent = dbStruct.presentChirp(viewerID, parent)
+		thread.Ancestors = append(thread.Ancestors, ThreadNode{ID: parent.ID, Chirp: &parent})
+		parentID = parent.InReplyTo
+	}
+	for i, j := 0, len(thread.Ancestors)-1; i < j; i, j = i+1, j-1 {
+		thread.Ancestors[i], thread.Ancestors[j] = thread.Ancestors[j], thread.Ancestors[i]
+	}
+
+	// The tree is built from every reply in the thread, so that replies to
+	// a chirp the viewer can't see still hang below its placeholder.
+	children := make(map[int][]threadEntry)
+	for _, val := range dbStruct.Chirps {
+		if val.ThreadRootID != chirp.ThreadRootID || val.InReplyTo == 0 {
+			continue
+		}
+		entry := threadEntry{position: val.Position()}
+		if dbStruct.chirpVisibleTo(viewerID, val) {
+			presented := dbStruct.presentChirp(viewerID, val)
+			entry.chirp = &presented
+		}
+		children[val.InReplyTo] = append(children[val.InReplyTo], entry)
+	}
+	for id, deleted := range dbStruct.DeletedReplies {
+		if deleted.ThreadRootID == chirp.ThreadRootID {
+			children[deleted.InReplyTo] = append(children[deleted.InReplyTo], threadEntry{
+				position: Position{CreatedAt: deleted.CreatedAt, ID: id},
+			})
+		}
+	}
+	for _, replies := range children {
+		sort.Slice(replies, func(i, j int) bool {
+			return replies[i].position.before(replies[j].position)
+		})
+	}
+
+	for _, entry := range children[chirp.ID] {
+		if after != nil && !after.before(entry.position) {
+			continue
+		}
+		if node, ok := buildThreadNode(entry, children, 1); ok {
+			thread.Replies = append(thread.Replies, node)
+		}
+		if limit > 0 && len(thread.Replies) > limit {
+			break
+		}
+	}
+	hasMore := limit > 0 && len(thread.Replies) > limit
+	if hasMore {
+		thread.Replies = thread.Replies[:limit]
+	}
+	return thread, hasMore, nil
+}
+
+// threadEntry is a reply while a thread is being built. chirp is nil when
+// the viewer can't see the reply or it was deleted.
+type threadEntry struct {
+	position Position
+	chirp    *Chirp
+}
+
+// buildThreadNode nests the replies below entry. It reports false for an
+// unavailable entry with nothing visible below it, which is left out.
+func buildThreadNode(entry threadEntry, children map[int][]threadEntry, depth int) (ThreadNode, bool) {
+	node := ThreadNode{
+		ID:          entry.position.ID,
+		Chirp:       entry.chirp,
+		Unavailable: entry.chirp == nil,
+		position:    entry.position,
+	}
+	if depth < maxThreadDepth {
+		for _, reply := range children[node.ID] {
+			if child, ok := buildThreadNode(reply, children, depth+1); ok {
+				node.Replies = append(node.Replies, child)
+			}
+		}
+	}
+	return node, !node.Unavailable || len(node.Replies) > 0
+}
+
+// Position returns where the node sits among its siblings, oldest first.
+// Unlike the embedded chirp's, it is also set for placeholders.
+func (n ThreadNode) Position() Position {
+	return n.position
+}
+
+// hasReplies reports whether any chirp, deleted or not, replied to id.
+func (dbStruct DBStructure) hasReplies(id int) bool {
+	for _, chirp := range dbStruct.Chirps {
+		if chirp.InReplyTo == id {
+			return true
+		}
+	}
+	for _, deleted := range dbStruct.DeletedReplies {
+		if deleted.InReplyTo == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parentOf returns the chirp that id replied to, including when id has
+// been deleted, or 0 if that isn't known.
+func (dbStruct DBStructure) parentOf(id int) int {
+	if chirp, ok := dbStruct.Chirps[id]; ok {
+		return chirp.InReplyTo
+	}
+	return dbStruct.DeletedReplies[id].InReplyTo
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+// threadShape reduces thread nodes to their IDs, with unavailable nodes
+// negated, so that whole trees can be compared.
+type threadShape struct {
+	ID      int
+	Replies []threadShape
+}
+
+func shapeOf(nodes []ThreadNode) []threadShape {
+	var shapes []threadShape
+	for _, node := range nodes {
+		id := node.ID
+		if node.Unavailable {
+			id = -id
+		}
+		shapes = append(shapes, threadShape{ID: id, Replies: shapeOf(node.Replies)})
+	}
+	return shapes
+}
+
+func TestGetThreadKeepsRepliesBelowUnavailableChirps(t *testing.T) {
+	db := newTestDB(t)
+	alice := addTestUser(t, db, "alice")
+	bob := addTestUser(t, db, "bob")
+	carol := addTestUser(t, db, "carol")
+
+	root := addTestChirp(t, db, Chirp{AuthorID: alice.ID})
+	deleted := addTestChirp(t, db, Chirp{AuthorID: bob.ID, InReplyTo: root.ID})
+	belowDeleted := addTestChirp(t, db, Chirp{AuthorID: alice.ID, InReplyTo: deleted.ID})
+	deeper := addTestChirp(t, db, Chirp{AuthorID: bob.ID, InReplyTo: belowDeleted.ID})
+	byCarol := addTestChirp(t, db, Chirp{AuthorID: carol.ID, InReplyTo: root.ID})
+	belowCarol := addTestChirp(t, db, Chirp{AuthorID: alice.ID, InReplyTo: byCarol.ID})
+	deletedLeaf := addTestChirp(t, db, Chirp{AuthorID: bob.ID, InReplyTo: root.ID})
+	private := addTestChirp(t, db, Chirp{AuthorID: carol.ID, InReplyTo: root.ID, Visibility: VisibilityPrivate})
+
+	for _, chirp := range []Chirp{deleted, deletedLeaf} {
+		if _, err := db.DeleteChirpByID(chirp.ID, chirp.AuthorID); err != nil {
+			t.Fatalf("DeleteChirpByID(%d): %v", chirp.ID, err)
+		}
+	}
+
+	// Bob and Carol can't see each other's replies.
+	if err := db.BlockUser(carol.ID, bob.ID); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		viewerID int
+		want     []threadShape
+	}{
+		{
+			name:     "deleted middle reply",
+			viewerID: 0,
+			want: []threadShape{
+				{ID: -deleted.ID, Replies: []threadShape{
+					{ID: belowDeleted.ID, Replies: []threadShape{{ID: deeper.ID}}},
+				}},
+				{ID: byCarol.ID, Replies: []threadShape{{ID: belowCarol.ID}}},
+			},
+		},
+		{
+			name:     "blocked middle reply",
+			viewerID: bob.ID,
+			want: []threadShape{
+				{ID: -deleted.ID, Replies: []threadShape{
+					{ID: belowDeleted.ID, Replies: []threadShape{{ID: deeper.ID}}},
+				}},
+				{ID: -byCarol.ID, Replies: []threadShape{{ID: belowCarol.ID}}},
+			},
+		},
+		{
+			name:     "author sees their private reply",
+			viewerID: carol.ID,
+			want: []threadShape{
+				{ID: -deleted.ID, Replies: []threadShape{{ID: belowDeleted.ID}}},
+				{ID: byCarol.ID, Replies: []threadShape{{ID: belowCarol.ID}}},
+				{ID: private.ID},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thread, hasMore, err := db.GetThread(root.ID, tt.viewerID, nil, 20)
+			if err != nil {
+				t.Fatalf("GetThread: %v", err)
+			}
+			if hasMore {
+				t.Errorf("hasMore = true, want false")
+			}
+			if got := shapeOf(thread.Replies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replies = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetThreadAncestorsPassDeletedReplies(t *testing.T) {
+	db := newTestDB(t)
+	alice := addTestUser(t, db, "alice")
+
+	root := addTestChirp(t, db, Chirp{AuthorID: alice.ID})
+	middle := addTestChirp(t, db, Chirp{AuthorID: alice.ID, InReplyTo: root.ID})
+	reply := addTestChirp(t, db, Chirp{AuthorID: alice.ID, InReplyTo: middle.ID})
+	if _, err := db.DeleteChirpByID(middle.ID, alice.ID); err != nil {
+		t.Fatalf("DeleteChirpByID: %v", err)
+	}
+
+	thread, _, err := db.GetThread(reply.ID, 0, nil, 20)
+	if err != nil {
+		t.Fatalf("GetThread: %v", err)
+	}
+	want := []threadShape{{ID: root.ID}, {ID: -middle.ID}}
+	if got := shapeOf(thread.Ancestors); !reflect.DeepEqual(got, want) {
+		t.Errorf("ancestors = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetThreadPagesPastPlaceholders(t *testing.T) {
+	db := newTestDB(t)
+	alice := addTestUser(t, db, "alice")
+
+	root := addTestChirp(t, db, Chirp{AuthorID: alice.ID})
+	deleted := addTestChirp(t, db, Chirp{AuthorID: alice.ID, InReplyTo: root.ID})
+	addTestChirp(t, db, Chirp{AuthorID: alice.ID, InReplyTo: deleted.ID})
+	last := addTestChirp(t, db, Chirp{AuthorID: alice.ID, InReplyTo: root.ID})
+	if _, err := db.DeleteChirpByID(deleted.ID, alice.ID); err != nil {
+		t.Fatalf("DeleteChirpByID: %v", err)
+	}
+
+	first, hasMore, err := db.GetThread(root.ID, 0, nil, 1)
+	if err != nil {
+		t.Fatalf("GetThread: %v", err)
+	}
+	if !hasMore || len(first.Replies) != 1 || first.Replies[0].ID != deleted.ID {
+		t.Fatalf("first page = %+v, hasMore %v; want placeholder %d and more", shapeOf(first.Replies), hasMore, deleted.ID)
+	}
+
+	after := first.Replies[0].Position()
+	second, hasMore, err := db.GetThread(root.ID, 0, &after, 1)
+	if err != nil {
+		t.Fatalf("GetThread: %v", err)
+	}
+	if hasMore || len(second.Replies) != 1 || second.Replies[0].ID != last.ID {
+		t.Errorf("second page = %+v, hasMore %v; want %d and no more", shapeOf(second.Replies), hasMore, last.ID)
+	}
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"time"
+)
+
+// migrations upgrade a database written by an older version of the server.
+// migrations[i] moves a database from schema version i to i+1, so new
+// migrations must only ever be appended.
+var migrations = []func(dbStruct *DBStructure, now time.Time){
+	backfillTimestamps,
+	backfillThreads,
+	backfillEntities,
+	backfillStatus,
+	backfillVisibility,
+	backfillRoles,
+}
+
+func migrateDB(dbStruct *DBStructure, now time.Time) {
+	for dbStruct.SchemaVersion < len(migrations) {
+		migrations[dbStruct.SchemaVersion](dbStruct, now)
+		dbStruct.SchemaVersion++
+	}
+}
+
+// ensureMaps initializes collections that are missing from databases written
+// before they were introduced.
+func (dbStruct *DBStructure) ensureMaps() {
+	if dbStruct.Chirps == nil {
+		dbStruct.Chirps = make(map[int]Chirp)
+	}
+	if dbStruct.Users == nil {
+		dbStruct.Users = make(map[int]User)
+	}
+	if dbStruct.RevokedTokens == nil {
+		dbStruct.RevokedTokens = make(map[string]RevokedToken)
+	}
+	if dbStruct.Blocks == nil {
+		dbStruct.Blocks = make(map[int][]int)
+	}
+	if dbStruct.Mutes == nil {
+		dbStruct.Mutes = make(map[int][]int)
+	}
+	if dbStruct.Follows == nil {
+		dbStruct.Follows = make(map[int][]int)
+	}
+	if dbStruct.Revisions == nil {
+		dbStruct.Revisions = make(map[int][]ChirpRevision)
+	}
+	if dbStruct.Likes == nil {
+		dbStruct.Likes = make(map[int][]Engagement)
+	}
+	if dbStruct.Rechirps == nil {
+		dbStruct.Rechirps = make(map[int][]Engagement)
+	}
+	if dbStruct.TagIndex == nil {
+		dbStruct.TagIndex = make(map[string][]int)
+	}
+	if dbStruct.MentionIndex == nil {
+		dbStruct.MentionIndex = make(map[int][]int)
+	}
+	if dbStruct.ContentFlags == nil {
+		dbStruct.ContentFlags = make(map[int][]ContentFlag)
+	}
+	if dbStruct.Media == nil {
+		dbStruct.Media = make(map[string]Media)
+	}
+	if dbStruct.PollVotes == nil {
+		dbStruct.PollVotes = make(map[int][]PollVote)
+	}
+	if dbStruct.Conversations == nil {
+		dbStruct.Conversations = make(map[int]Conversation)
+	}
+	if dbStruct.Messages == nil {
+		dbStruct.Messages = make(map[int][]Message)
+	}
+	if dbStruct.Bookmarks == nil {
+		dbStruct.Bookmarks = make(map[int][]Bookmark)
+	}
+	if dbStruct.Lists == nil {
+		dbStruct.Lists = make(map[int]List)
+	}
+	if dbStruct.Pins == nil {
+		dbStruct.Pins = make(map[int][]Pin)
+	}
+	if dbStruct.Preferences == nil {
+		dbStruct.Preferences = make(map[int]Preferences)
+	}
+	if dbStruct.Reports == nil {
+		dbStruct.Reports = make(map[int]Report)
+	}
+	if dbStruct.ModerationLog == nil {
+		dbStruct.ModerationLog = make([]ModerationAction, 0)
+	}
+	if dbStruct.Warnings == nil {
+		dbStruct.Warnings = make(map[int][]Warning)
+	}
+	if dbStruct.Sessions == nil {
+		dbStruct.Sessions = make(map[string]Session)
+	}
+	if dbStruct.DeletedReplies == nil {
+		dbStruct.DeletedReplies = make(map[int]DeletedReply)
+	}
+}
+
+// backfillTimestamps stamps rows created before chirps and users carried
+// timestamps. The real creation time is unknown, so they get the migration
+// time and keep their relative order through their IDs.
+func backfillTimestamps(dbStruct *DBStructure, now time.Time) {
+	for id, chirp := range dbStruct.Chirps {
+		if chirp.CreatedAt.IsZero() {
+			chirp.CreatedAt = now
+			chirp.UpdatedAt = now
+			dbStruct.Chirps[id] = chirp
+		}
+	}
+	for id, user := range dbStruct.Users {
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = now
+			user.UpdatedAt = now
+			dbStruct.Users[id] = user
+		}
+	}
+}
+
+// backfillThreads makes every existing chirp the root of its own thread and
+// starts the chirp ID counter after the highest ID in use.
+func backfillThreads(dbStruct *DBStructure, now time.Time) {
+	for id, chirp := range dbStruct.Chirps {
+		if chirp.ThreadRootID == 0 {
+			chirp.ThreadRootID = chirp.ID
+			dbStruct.Chirps[id] = chirp
+		}
+		if chirp.ID > dbStruct.LastChirpID {
+			dbStruct.LastChirpID = chirp.ID
+		}
+	}
+}
+
+// backfillEntities extracts hashtags and mentions from existing chirps and
+// builds the indexes over them.
+func backfillEntities(dbStruct *DBStructure, now time.Time) {
+	for id, chirp := range dbStruct.Chirps {
+		dbStruct.Chirps[id] = dbStruct.indexChirp(chirp)
+	}
+}
+
+// backfillStatus marks every existing chirp as published; drafts and
+// scheduled chirps didn't exist before.
+func backfillStatus(dbStruct *DBStructure, now time.Time) {
+	for id, chirp := range dbStruct.Chirps {
+		if chirp.Status == "" {
+			chirp.Status = ChirpPublished
+			dbStruct.Chirps[id] = chirp
+		}
+	}
+}
+
+// backfillVisibility makes every existing chirp public, which is what they
+// all were before visibility levels.
+func backfillVisibility(dbStruct *DBStructure, now time.Time) {
+	for id, chirp := range dbStruct.Chirps {
+		if chirp.Visibility == "" {
+			chirp.Visibility = VisibilityPublic
+			dbStruct.Chirps[id] = chirp
+		}
+	}
+}
+
+// backfillRoles gives every existing user the user role. Moderators used to
+// be configured outside the store and have to be given their role again.
+func backfillRoles(dbStruct *DBStructure, now time.Time) {
+	for id, user := range dbStruct.Users {
+		if user.Role == "" {
+			user.Role = RoleUser
+			dbStruct.Users[id] = user
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package database
+
+// presentChirp prepares a stored chirp to be returned to viewerID, filling
+// in the fields that are derived at read time.
+func (dbStruct DBStructure) presentChirp(viewerID int, chirp Chirp) Chirp {
+	chirp.Quoted = nil
+	if chirp.QuoteOf != 0 {
+		chirp.Quoted = dbStruct.quotedSnapshot(viewerID, chirp.QuoteOf)
+	}
+	chirp.Media = dbStruct.mediaAttachments(chirp.MediaIDs)
+	chirp.Poll = dbStruct.presentPoll(viewerID, chirp)
+	chirp.Collapsed = chirp.flaggedSensitive() && viewerID != chirp.AuthorID &&
+		dbStruct.sensitivePreference(viewerID) != SensitiveExpand
+	return chirp
+}
+
+func (dbStruct DBStructure) presentChirps(viewerID int, chirps []Chirp) []Chirp {
+	for i, chirp := range chirps {
+		chirps[i] = dbStruct.presentChirp(viewerID, chirp)
+	}
+	return chirps
+}
+
+func (dbStruct DBStructure) quotedSnapshot(viewerID, quotedID int) *QuotedChirp {
+	quoted, ok := dbStruct.Chirps[quotedID]
+	if !ok || !dbStruct.chirpVisibleTo(viewerID, quoted) {
+		return &QuotedChirp{ID: quotedID, Unavailable: true}
+	}
+
+	snapshot := &QuotedChirp{
+		ID:        quoted.ID,
+		Body:      quoted.Body,
+		Author:    &ChirpAuthor{ID: quoted.AuthorID},
+		CreatedAt: &quoted.CreatedAt,
+	}
+	if author, ok := dbStruct.Users[quoted.AuthorID]; ok {
+		snapshot.Author.Handle = author.Handle
+		snapshot.Author.ChirpyRed = author.ChirpyRed
+	}
+	return snapshot
+}
+
+func (dbStruct DBStructure) mediaAttachments(ids []string) []MediaAttachment {
+	if len(ids) == 0 {
+		return nil
+	}
+	attachments := make([]MediaAttachment, 0, len(ids))
+	for _, id := range ids {
+		if media, ok := dbStruct.Media[id]; ok {
+			attachments = append(attachments, media.Attachment())
+		}
+	}
+	return attachments
+}
+
+// Attachment describes the upload the way chirps show it.
+func (m Media) Attachment() MediaAttachment {
+	return MediaAttachment{
+		ID:           m.ID,
+		URL:          "/api/media/" + m.ID,
+		ThumbnailURL: "/api/media/" + m.ID + "/thumbnail",
+		ContentType:  m.ContentType,
+		Width:        m.Width,
+		Height:       m.Height,
+	}
+}
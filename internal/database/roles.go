@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// Roles decide what a user may do beyond using the API as themselves. Each
+// role may do everything the roles below it can.
+const (
+	RoleUser = "user"
+	// RoleModerator users work the report queue and moderate chirps.
+	RoleModerator = "moderator"
+	// RoleAdmin users also manage roles and see server metrics.
+	RoleAdmin = "admin"
+)
+
+var (
+	ErrInvalidRole = errors.New("Role must be user, moderator or admin")
+	ErrAdminExists = errors.New("There already is an admin")
+	ErrLastAdmin   = errors.New("Cannot remove the last admin")
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// ValidRole reports whether role is one of the Roles.
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants everything required does.
+// Unknown roles grant nothing beyond RoleUser.
+func RoleAtLeast(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
+
+// HasRole reports whether the user's role grants everything role does.
+func (u User) HasRole(role string) bool {
+	return RoleAtLeast(u.Role, role)
+}
+
+// SetUserRole changes a user's role. The last admin can't be demoted, so
+// that there is always someone left to manage roles.
+func (db *DB) SetUserRole(userID int, role string) (User, error) {
+	if !ValidRole(role) {
+		return User{}, ErrInvalidRole
+	}
+
+	var user User
+	err := db.update(func(dbStruct *DBStructure) error {
+		var ok bool
+		user, ok = dbStruct.Users[userID]
+		if !ok {
+			return ErrUserNotFound
+		}
+		if user.Role == RoleAdmin && role != RoleAdmin && dbStruct.adminCount() == 1 {
+			return ErrLastAdmin
+		}
+
+		user.Role = role
+		user.UpdatedAt = time.Now().UTC()
+		dbStruct.Users[userID] = user
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user.withoutPassword(), nil
+}
+
+// BootstrapAdmin makes the user with the given email the first admin. It
+// fails once there is an admin; from then on admins manage roles through
+// the API.
+func (db *DB) BootstrapAdmin(email string) (User, error) {
+	var user User
+	err := db.update(func(dbStruct *DBStructure) error {
+		if dbStruct.adminCount() > 0 {
+			return ErrAdminExists
+		}
+		var ok bool
+		user, ok = dbStruct.userByEmail(email)
+		if !ok {
+			return ErrUserNotFound
+		}
+
+		user.Role = RoleAdmin
+		user.UpdatedAt = time.Now().UTC()
+		dbStruct.Users[user.ID] = user
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user.withoutPassword(), nil
+}
+
+func (dbStruct DBStructure) adminCount() int {
+	count := 0
+	for _, user := range dbStruct.Users {
+		if user.Role == RoleAdmin {
+			count++
+		}
+	}
+	return count
+}
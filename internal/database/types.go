@@ -3,24 +3,292 @@ package database
 import (
 	"sync"
 	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/search"
 )
 
 type Chirp struct {
-	ID       int    `json:"id"`
-	Body     string `json:"body"`
-	AuthorID int    `json:"author_id"`
+	ID        int    `json:"id"`
+	Body      string `json:"body"`
+	AuthorID  int    `json:"author_id"`
+	InReplyTo int    `json:"in_reply_to,omitempty"`
+	// ThreadRootID is the chirp that started the conversation; it is the
+	// chirp's own ID for chirps that aren't replies.
+	ThreadRootID int `json:"thread_root_id"`
+	ReplyCount   int `json:"reply_count"`
+	QuoteOf      int `json:"quote_of,omitempty"`
+	QuoteCount   int `json:"quote_count"`
+	LikeCount    int `json:"like_count"`
+	RechirpCount int `json:"rechirp_count"`
+	// RechirpedBy is set on chirps that appear in an author feed because
+	// that author rechirped them.
+	RechirpedBy int `json:"rechirped_by,omitempty"`
+	// Pinned is set on the pinned chirps at the top of an author feed.
+	Pinned bool `json:"pinned,omitempty"`
+	// Quoted embeds the chirp named by QuoteOf. It is filled in when the
+	// chirp is read and never stored.
+	Quoted *QuotedChirp `json:"quoted,omitempty"`
+	// MediaIDs are the uploads attached to the chirp, in order.
+	MediaIDs []string `json:"media_ids,omitempty"`
+	// Media describes the attachments named by MediaIDs. Like Quoted, it is
+	// filled in when the chirp is read.
+	Media []MediaAttachment `json:"media,omitempty"`
+	// ContentWarning is shown in place of the body until the viewer chooses
+	// to read on, and Sensitive marks the attached media as sensitive.
+	// Collapsed is set when the viewer's preferences ask for such chirps to
+	// be shown collapsed; it is filled in when the chirp is read.
+	ContentWarning string `json:"content_warning,omitempty"`
+	Sensitive      bool   `json:"sensitive"`
+	Collapsed      bool   `json:"collapsed,omitempty"`
+	// Hidden is set when a moderator hides the chirp. Hidden chirps are kept
+	// for appeals but left out of every read path.
+	Hidden bool `json:"hidden,omitempty"`
+	// Visibility is one of the Visibility levels.
+	Visibility string `json:"visibility"`
+	// Poll is the chirp's poll, if it has one.
+	Poll *Poll `json:"poll,omitempty"`
+	// Entities are the hashtags and mentions in Body, set by the store.
+	Entities []Entity `json:"entities"`
+	Edited   bool     `json:"edited"`
+	// Status is ChirpPublished, or ChirpDraft or ChirpScheduled for chirps
+	// nobody but their author can see yet.
+	Status string `json:"status"`
+	// PublishAt is when a scheduled chirp is due to be published.
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// CreatedAt is when the chirp was published, or when it was saved for
+	// chirps that haven't been published yet.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QuotedChirp is a snapshot of a quoted chirp and its author. When the
+// original is deleted or hidden from the viewer only its ID remains and
+// Unavailable is set.
+type QuotedChirp struct {
+	ID          int          `json:"id"`
+	Body        string       `json:"body,omitempty"`
+	Author      *ChirpAuthor `json:"author,omitempty"`
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+	Unavailable bool         `json:"unavailable,omitempty"`
+}
+
+type ChirpAuthor struct {
+	ID        int    `json:"id"`
+	Handle    string `json:"handle,omitempty"`
+	ChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+// Media is an uploaded image. The image and its thumbnail are kept in a
+// blob store under Key and ThumbnailKey.
+type Media struct {
+	ID            string    `json:"id"`
+	OwnerID       int       `json:"owner_id"`
+	ContentType   string    `json:"content_type"`
+	Key           string    `json:"key"`
+	Size          int       `json:"size"`
+	Width         int       `json:"width"`
+	Height        int       `json:"height"`
+	ThumbnailType string    `json:"thumbnail_type"`
+	ThumbnailKey  string    `json:"thumbnail_key"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Public is filled in when the upload is read. It is set while the
+	// upload is attached to a public chirp anyone can read.
+	Public bool `json:"-"`
+}
+
+// MediaAttachment is how an attached upload is shown on a chirp.
+type MediaAttachment struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// Engagement records a user liking or rechirping a chirp.
+type Engagement struct {
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChirpRevision is one version of an edited chirp's body.
+type ChirpRevision struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContentFlag records a content filter rule asking for a chirp to be
+// reviewed by a moderator.
+type ContentFlag struct {
+	Rule      string    `json:"rule"`
+	Match     string    `json:"match"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Conversation is a private exchange of messages between two or more users.
+// Its messages are kept apart from chirps and never appear in chirp reads.
+type Conversation struct {
+	ID        int   `json:"id"`
+	MemberIDs []int `json:"member_ids"`
+	// LastMessageAt is when the latest message was sent, or when the
+	// conversation was started if it has no messages yet.
+	LastMessageAt time.Time     `json:"last_message_at"`
+	ReadReceipts  []ReadReceipt `json:"read_receipts"`
+	// LastMessage and UnreadCount are filled in for the member reading the
+	// conversation and never stored.
+	LastMessage *Message  `json:"last_message,omitempty"`
+	UnreadCount int       `json:"unread_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReadReceipt records the latest message a member has read.
+type ReadReceipt struct {
+	UserID    int       `json:"user_id"`
+	MessageID int       `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+type Message struct {
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversation_id"`
+	SenderID       int       `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Bookmark is a chirp a user saved for later. Bookmarks are private.
+type Bookmark struct {
+	ChirpID   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// List is a named set of accounts with its own timeline. Private lists can
+// only be seen by their owner; public ones can be subscribed to.
+type List struct {
+	ID          int    `json:"id"`
+	OwnerID     int    `json:"owner_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+	MemberIDs   []int  `json:"member_ids"`
+	// SubscriberIDs is only shown to the owner. SubscriberCount and
+	// Subscribed are filled in when the list is read.
+	SubscriberIDs   []int     `json:"subscriber_ids,omitempty"`
+	SubscriberCount int       `json:"subscriber_count"`
+	Subscribed      bool      `json:"subscribed"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Pin is a chirp its author pinned to the top of their feed.
+type Pin struct {
+	ChirpID   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Preferences are a user's viewing preferences.
+type Preferences struct {
+	// SensitiveContent is how chirps with a content warning or sensitive
+	// media are shown: SensitiveCollapse, SensitiveExpand or
+	// SensitiveFilter.
+	SensitiveContent string `json:"sensitive_content"`
+}
+
+// Report is a user's complaint about a chirp or an account, waiting in the
+// moderation queue. Reports raised by the content filter have a ReporterID
+// of 0.
+type Report struct {
+	ID         int    `json:"id"`
+	ReporterID int    `json:"reporter_id"`
+	TargetType string `json:"target_type"`
+	// ChirpID is set for chirp reports; UserID is the reported account, or
+	// the author of the reported chirp.
+	ChirpID    int        `json:"chirp_id,omitempty"`
+	UserID     int        `json:"user_id"`
+	Reason     string     `json:"reason"`
+	Comment    string     `json:"comment,omitempty"`
+	Status     string     `json:"status"`
+	ClaimedBy  int        `json:"claimed_by,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+	ResolvedBy int        `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	// Chirp is the reported chirp as stored, hidden or not, filled in for
+	// moderators.
+	Chirp     *Chirp    `json:"chirp,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ModerationAction is an entry in the moderation audit trail.
+type ModerationAction struct {
+	ID          int       `json:"id"`
+	ModeratorID int       `json:"moderator_id"`
+	Action      string    `json:"action"`
+	ReportID    int       `json:"report_id,omitempty"`
+	ChirpID     int       `json:"chirp_id,omitempty"`
+	UserID      int       `json:"user_id,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Warning is a moderator's warning to a user.
+type Warning struct {
+	ReportID  int       `json:"report_id"`
+	Reason    string    `json:"reason"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type User struct {
 	ID        int    `json:"id"`
 	Email     string `json:"email"`
+	Handle    string `json:"handle,omitempty"`
 	Password  []byte `json:"Password,omitempty"`
 	ChirpyRed bool   `json:"is_chirpy_red"`
+	Role      string `json:"role"`
+	// SuspendedUntil is when the user's suspension ends, if they are
+	// suspended. Banned users are suspended until the ban is lifted.
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	Banned           bool       `json:"banned,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	// ChirpsHidden hides the user's chirps while they are suspended.
+	ChirpsHidden bool `json:"chirps_hidden,omitempty"`
+	// PasswordResetRequired locks the account out of everything but
+	// changing its password.
+	PasswordResetRequired bool      `json:"password_reset_required,omitempty"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 type DB struct {
-	path string
-	mux  *sync.RWMutex
+	path       string
+	mux        *sync.RWMutex
+	searchPath string
+	search     *search.Index
+}
+
+// Session is a login, or an admin impersonating the user when
+// ImpersonatorID is set. The tokens issued for it carry its ID and stop
+// working once it is revoked.
+type Session struct {
+	ID             string     `json:"id"`
+	UserID         int        `json:"user_id"`
+	ImpersonatorID int        `json:"impersonator_id,omitempty"`
+	IP             string     `json:"ip,omitempty"`
+	UserAgent      string     `json:"user_agent,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     time.Time  `json:"last_used_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// DeletedReply is what is left of a deleted reply that had replies of its
+// own.
+type DeletedReply struct {
+	InReplyTo    int       `json:"in_reply_to"`
+	ThreadRootID int       `json:"thread_root_id"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type RevokedToken struct {
@@ -29,7 +297,44 @@ type RevokedToken struct {
 }
 
 type DBStructure struct {
+	SchemaVersion int                     `json:"schema_version"`
+	LastChirpID   int                     `json:"last_chirp_id"`
 	Chirps        map[int]Chirp           `json:"chirps"`
 	Users         map[int]User            `json:"users"`
 	RevokedTokens map[string]RevokedToken `json:"revoked_tokens"`
+	Blocks        map[int][]int           `json:"blocks"`
+	Mutes         map[int][]int           `json:"mutes"`
+	// Follows maps a user to the users they follow.
+	Follows      map[int][]int           `json:"follows"`
+	Revisions    map[int][]ChirpRevision `json:"revisions"`
+	Likes        map[int][]Engagement    `json:"likes"`
+	Rechirps     map[int][]Engagement    `json:"rechirps"`
+	TagIndex     map[string][]int        `json:"tag_index"`
+	MentionIndex map[int][]int           `json:"mention_index"`
+	ContentFlags map[int][]ContentFlag   `json:"content_flags"`
+	Media        map[string]Media        `json:"media"`
+	PollVotes    map[int][]PollVote      `json:"poll_votes"`
+	// Conversations and Messages hold direct messages. Messages maps a
+	// conversation to its messages, oldest first.
+	LastConversationID int                  `json:"last_conversation_id"`
+	LastMessageID      int                  `json:"last_message_id"`
+	Conversations      map[int]Conversation `json:"conversations"`
+	Messages           map[int][]Message    `json:"messages"`
+	// Bookmarks maps a user to their bookmarks, oldest first.
+	Bookmarks  map[int][]Bookmark `json:"bookmarks"`
+	LastListID int                `json:"last_list_id"`
+	Lists      map[int]List       `json:"lists"`
+	// Pins maps a user to their pinned chirps, oldest pin first.
+	Pins        map[int][]Pin       `json:"pins"`
+	Preferences map[int]Preferences `json:"preferences"`
+	// Reports is the moderation queue and ModerationLog the audit trail of
+	// what moderators did, oldest first.
+	LastReportID  int                `json:"last_report_id"`
+	Reports       map[int]Report     `json:"reports"`
+	ModerationLog []ModerationAction `json:"moderation_log"`
+	Warnings      map[int][]Warning  `json:"warnings"`
+	Sessions      map[string]Session `json:"sessions"`
+	// DeletedReplies keeps where deleted replies sat in their thread, so
+	// that the replies to them stay reachable.
+	DeletedReplies map[int]DeletedReply `json:"deleted_replies"`
 }
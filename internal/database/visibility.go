@@ -0,0 +1,52 @@
+package database
+
+import "errors"
+
+// Visibility levels decide who can read a chirp.
+const (
+	// VisibilityPublic chirps can be read by anyone and appear everywhere.
+	VisibilityPublic = "public"
+	// VisibilityUnlisted chirps can be read by anyone, but are left out of
+	// the global timeline, hashtag pages, search and trends.
+	VisibilityUnlisted = "unlisted"
+	// VisibilityFollowers chirps can only be read by the author's followers.
+	VisibilityFollowers = "followers"
+	// VisibilityPrivate chirps can only be read by their author.
+	VisibilityPrivate = "private"
+)
+
+var ErrInvalidVisibility = errors.New("Visibility must be public, unlisted, followers or private")
+
+// ValidVisibility reports whether visibility is one of the Visibility levels.
+func ValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityFollowers, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// allowsViewer reports whether the chirp's visibility lets viewerID read it,
+// before blocks and mutes are applied.
+func (dbStruct DBStructure) allowsViewer(viewerID int, chirp Chirp) bool {
+	if viewerID != 0 && viewerID == chirp.AuthorID {
+		return true
+	}
+	switch chirp.Visibility {
+	case VisibilityFollowers:
+		return containsID(dbStruct.Follows[viewerID], chirp.AuthorID)
+	case VisibilityPrivate:
+		return false
+	}
+	return true
+}
+
+// chirpListedTo reports whether a chirp belongs in listings that aren't
+// about its author, such as the global timeline and search results. Unlisted
+// chirps only show up there for their author.
+func (dbStruct DBStructure) chirpListedTo(viewerID int, chirp Chirp) bool {
+	if chirp.Visibility == VisibilityUnlisted && viewerID != chirp.AuthorID {
+		return false
+	}
+	return dbStruct.chirpVisibleTo(viewerID, chirp)
+}
@@ -0,0 +1,183 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("Only JPEG, PNG, GIF and WebP images are supported")
+	ErrTooLarge        = errors.New("Image dimensions are too large")
+	ErrInvalidImage    = errors.New("Image couldn't be decoded")
+)
+
+const (
+	// MaxDimension bounds the width and height of uploads, so that a small
+	// file can't decode into an enormous bitmap.
+	MaxDimension = 8192
+	// ThumbnailSize is the longest side of a thumbnail.
+	ThumbnailSize = 400
+
+	jpegQuality = 90
+)
+
+// Image is a processed upload, re-encoded without its metadata, and its
+// thumbnail.
+type Image struct {
+	Data          []byte
+	ContentType   string
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// Process checks that data is a supported image, judging by its content
+// rather than any name or declared type, and re-encodes it. Re-encoding
+// drops EXIF and every other kind of embedded metadata; a JPEG's EXIF
+// orientation is applied to the pixels first so the image still displays
+// the right way up. WebP images are converted to PNG.
+func Process(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return Image{}, ErrUnsupportedType
+	}
+
+	var config image.Config
+	var err error
+	if contentType == "image/webp" {
+		config, err = webp.DecodeConfig(bytes.NewReader(data))
+	} else {
+		config, _, err = image.DecodeConfig(bytes.NewReader(data))
+	}
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return Image{}, ErrTooLarge
+	}
+
+	var processed Image
+	var first image.Image
+	switch contentType {
+	case "image/gif":
+		processed, first, err = processGIF(data)
+	case "image/jpeg":
+		processed, first, err = processJPEG(data)
+	case "image/png":
+		processed, first, err = processStill(data, png.Decode)
+	case "image/webp":
+		processed, first, err = processStill(data, webp.Decode)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	bounds := first.Bounds()
+	processed.Width, processed.Height = bounds.Dx(), bounds.Dy()
+	processed.ThumbnailType = processed.ContentType
+	if processed.ThumbnailType == "image/gif" {
+		processed.ThumbnailType = "image/png"
+	}
+	processed.Thumbnail, err = encode(thumbnail(first), processed.ThumbnailType)
+	if err != nil {
+		return Image{}, err
+	}
+	return processed, nil
+}
+
+func processJPEG(data []byte) (Image, image.Image, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, nil, ErrInvalidImage
+	}
+	img = orient(img, jpegOrientation(data))
+	encoded, err := encode(img, "image/jpeg")
+	if err != nil {
+		return Image{}, nil, err
+	}
+	return Image{Data: encoded, ContentType: "image/jpeg"}, img, nil
+}
+
+// processStill re-encodes a single-frame image as PNG.
+func processStill(data []byte, decode func(r io.Reader) (image.Image, error)) (Image, image.Image, error) {
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, nil, ErrInvalidImage
+	}
+	encoded, err := encode(img, "image/png")
+	if err != nil {
+		return Image{}, nil, err
+	}
+	return Image{Data: encoded, ContentType: "image/png"}, img, nil
+}
+
+// processGIF keeps every frame of an animation but none of its comment or
+// application extensions.
+func processGIF(data []byte) (Image, image.Image, error) {
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(animation.Image) == 0 {
+		return Image{}, nil, ErrInvalidImage
+	}
+	var buf bytes.Buffer
+	err = gif.EncodeAll(&buf, &gif.GIF{
+		Image:           animation.Image,
+		Delay:           animation.Delay,
+		LoopCount:       animation.LoopCount,
+		Disposal:        animation.Disposal,
+		Config:          animation.Config,
+		BackgroundIndex: animation.BackgroundIndex,
+	})
+	if err != nil {
+		return Image{}, nil, err
+	}
+	return Image{Data: buf.Bytes(), ContentType: "image/gif"}, animation.Image[0], nil
+}
+
+// thumbnail scales img down to fit within ThumbnailSize, keeping its aspect
+// ratio. Smaller images are left at their size.
+func thumbnail(img image.Image) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > ThumbnailSize || h > ThumbnailSize {
+		if w >= h {
+			w, h = ThumbnailSize, h*ThumbnailSize/w
+		} else {
+			w, h = w*ThumbnailSize/h, ThumbnailSize
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(out, out.Bounds(), img, bounds, draw.Src, nil)
+	return out
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,202 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.RGBA{R: 255, A: 255}
+	blue = color.RGBA{B: 255, A: 255}
+)
+
+// testJPEG encodes a 32x16 image whose top-left quadrant is red and the
+// rest blue, with the given segments inserted after the start of image.
+func testJPEG(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			if x < 16 && y < 8 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("encoding test JPEG: %v", err)
+	}
+	encoded := buf.Bytes()
+	out := append([]byte{}, encoded[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, encoded[2:]...)
+}
+
+// exifSegment builds an APP1 segment holding only an orientation tag.
+func exifSegment(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	return segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func segment(marker byte, payload []byte) []byte {
+	out := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(out[2:], uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+func isColor(c color.Color, want color.RGBA) bool {
+	r, g, b, _ := c.RGBA()
+	wr, wg, wb, _ := want.RGBA()
+	near := func(a, b uint32) bool { return a+0x2000 > b && b+0x2000 > a }
+	return near(r, wr) && near(g, wg) && near(b, wb)
+}
+
+func TestProcessAppliesEXIFOrientation(t *testing.T) {
+	// corner is where the red quadrant ends up once the image is upright:
+	// 0 top-left, 1 top-right, 2 bottom-right, 3 bottom-left.
+	tests := []struct {
+		name          string
+		order         binary.ByteOrder
+		orientation   uint16
+		width, height int
+		corner        int
+	}{
+		{"normal", binary.BigEndian, 1, 32, 16, 0},
+		{"mirrored", binary.LittleEndian, 2, 32, 16, 1},
+		{"rotated 180", binary.BigEndian, 3, 32, 16, 2},
+		{"flipped", binary.LittleEndian, 4, 32, 16, 3},
+		{"transposed", binary.BigEndian, 5, 16, 32, 0},
+		{"rotated 90 clockwise", binary.LittleEndian, 6, 16, 32, 1},
+		{"transversed", binary.BigEndian, 7, 16, 32, 2},
+		{"rotated 90 counter-clockwise", binary.LittleEndian, 8, 16, 32, 3},
+		{"invalid orientation", binary.BigEndian, 9, 32, 16, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, err := Process(testJPEG(t, exifSegment(tt.order, tt.orientation)))
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if processed.ContentType != "image/jpeg" {
+				t.Errorf("ContentType = %q, want image/jpeg", processed.ContentType)
+			}
+			if processed.Width != tt.width || processed.Height != tt.height {
+				t.Errorf("size = %dx%d, want %dx%d", processed.Width, processed.Height, tt.width, tt.height)
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(processed.Data))
+			if err != nil {
+				t.Fatalf("decoding result: %v", err)
+			}
+			if img.Bounds().Dx() != tt.width || img.Bounds().Dy() != tt.height {
+				t.Fatalf("decoded size = %v, want %dx%d", img.Bounds().Size(), tt.width, tt.height)
+			}
+			// Quadrant centers, clockwise from the top left.
+			qx, qy := tt.width/4, tt.height/4
+			centers := []image.Point{{qx, qy}, {3 * qx, qy}, {3 * qx, 3 * qy}, {qx, 3 * qy}}
+			for i, center := range centers {
+				want := blue
+				if i == tt.corner {
+					want = red
+				}
+				if got := img.At(center.X, center.Y); !isColor(got, want) {
+					t.Errorf("quadrant %d is %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessStripsMetadata(t *testing.T) {
+	data := testJPEG(t,
+		exifSegment(binary.BigEndian, 6),
+		segment(0xFE, []byte("shot at 51.5007N 0.1246W")),
+	)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("test image has orientation %d, want 6", jpegOrientation(data))
+	}
+
+	processed, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	for _, leak := range []string{"Exif", "51.5007N"} {
+		if bytes.Contains(processed.Data, []byte(leak)) {
+			t.Errorf("processed image still contains %q", leak)
+		}
+		if bytes.Contains(processed.Thumbnail, []byte(leak)) {
+			t.Errorf("thumbnail still contains %q", leak)
+		}
+	}
+	if got := jpegOrientation(processed.Data); got != 1 {
+		t.Errorf("processed image has orientation %d, want none", got)
+	}
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encoding test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessChecksSizeAndType(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"widest allowed", testPNG(t, MaxDimension, 1), nil},
+		{"tallest allowed", testPNG(t, 1, MaxDimension), nil},
+		{"too wide", testPNG(t, MaxDimension+1, 1), ErrTooLarge},
+		{"too tall", testPNG(t, 1, MaxDimension+1), ErrTooLarge},
+		{"truncated GIF", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"), ErrInvalidImage},
+		{"plain text", []byte("hello, world"), ErrUnsupportedType},
+		{"truncated PNG", testPNG(t, 10, 10)[:40], ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, err := Process(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if processed.ContentType != "image/png" || processed.ThumbnailType != "image/png" {
+				t.Errorf("types = %q and %q, want image/png", processed.ContentType, processed.ThumbnailType)
+			}
+			thumb, err := png.DecodeConfig(bytes.NewReader(processed.Thumbnail))
+			if err != nil {
+				t.Fatalf("decoding thumbnail: %v", err)
+			}
+			if thumb.Width > ThumbnailSize || thumb.Height > ThumbnailSize {
+				t.Errorf("thumbnail is %dx%d, larger than %d", thumb.Width, thumb.Height, ThumbnailSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+)
+
+// maxSleep bounds how long the scheduler waits between checks, so that it
+// notices chirps scheduled by anything that didn't wake it.
+const maxSleep = time.Minute
+
+// Store is the part of the database the scheduler works on. *database.DB
+// implements it.
+type Store interface {
+	PublishDueChirps(now time.Time) ([]database.Chirp, error)
+	CloseExpiredPolls(now time.Time) ([]database.Chirp, error)
+	LiftExpiredSuspensions(now time.Time) ([]database.User, error)
+	NextDueAt() (time.Time, bool, error)
+}
+
+// Scheduler publishes scheduled chirps, closes polls and lifts suspensions
+// when they fall due.
+// Everything it needs is in the store, so work that fell due while the
+// server was down is done as soon as it starts again.
+type Scheduler struct {
+	store Store
+	wake  chan struct{}
+}
+
+func New(store Store) *Scheduler {
+	return &Scheduler{
+		store: store,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Wake makes the scheduler check again for the next thing due, after a
+// chirp has been scheduled, rescheduled or published with a poll, or a user
+// has been suspended.
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run does due work until stop is closed. onPublish, if set, is
+// called for every chirp published.
+func (s *Scheduler) Run(stop <-chan struct{}, onPublish func(database.Chirp), onError func(error)) {
+	for {
+		sleep := s.tick(onPublish, onError)
+		timer := time.NewTimer(sleep)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// tick does the work that is due and returns how long to sleep before more
+// is.
+func (s *Scheduler) tick(onPublish func(database.Chirp), onError func(error)) time.Duration {
+	now := time.Now().UTC()
+	published, err := s.store.PublishDueChirps(now)
+	if err != nil {
+		if onError != nil {
+			onError(err)
+		}
+		return maxSleep
+	}
+	if onPublish != nil {
+		for _, chirp := range published {
+			onPublish(chirp)
+		}
+	}
+
+	_, err = s.store.CloseExpiredPolls(now)
+	if err != nil {
+		if onError != nil {
+			onError(err)
+		}
+		return maxSleep
+	}
+
+	_, err = s.store.LiftExpiredSuspensions(now)
+	if err != nil {
+		if onError != nil {
+			onError(err)
+		}
+		return maxSleep
+	}
+
+	next, ok, err := s.store.NextDueAt()
+	if err != nil {
+		if onError != nil {
+			onError(err)
+		}
+		return maxSleep
+	}
+	if !ok {
+		return maxSleep
+	}
+	sleep := next.Sub(time.Now())
+	if sleep < 0 {
+		sleep = 0
+	}
+	if sleep > maxSleep {
+		sleep = maxSleep
+	}
+	return sleep
+}
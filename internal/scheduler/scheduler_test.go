@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+)
+
+// runScheduler runs a scheduler on db until the test ends and returns the
+// chirps it publishes.
+func runScheduler(t *testing.T, db *database.DB) (*Scheduler, <-chan database.Chirp) {
+	t.Helper()
+	s := New(db)
+	published := make(chan database.Chirp, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(stop, func(chirp database.Chirp) { published <- chirp }, func(err error) {
+			t.Errorf("scheduler: %v", err)
+		})
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	return s, published
+}
+
+func waitForPublish(t *testing.T, published <-chan database.Chirp, wantID int) {
+	t.Helper()
+	select {
+	case chirp := <-published:
+		if chirp.ID != wantID {
+			t.Errorf("published chirp %d, want %d", chirp.ID, wantID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("chirp %d wasn't published", wantID)
+	}
+}
+
+func TestPublishesChirpsDueWhileStopped(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.NewDB(dir)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	author, err := db.AddUser("password", "author@example.com", "")
+	if err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	due := time.Now().Add(-time.Hour)
+	chirp, err := db.CreateChirp(database.Chirp{
+		AuthorID:  author.ID,
+		Body:      "while you were out",
+		Status:    database.ChirpScheduled,
+		PublishAt: &due,
+	})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+
+	// A new DB over the same directory stands in for a restarted server.
+	reopened, err := database.NewDB(dir)
+	if err != nil {
+		t.Fatalf("NewDB after restart: %v", err)
+	}
+	_, published := runScheduler(t, reopened)
+	waitForPublish(t, published, chirp.ID)
+
+	if _, err := reopened.GetChirpByID(chirp.ID, 0); err != nil {
+		t.Errorf("GetChirpByID after publishing: %v", err)
+	}
+}
+
+func TestWakePicksUpNewlyScheduledChirps(t *testing.T) {
+	db, err := database.NewDB(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	author, err := db.AddUser("password", "author@example.com", "")
+	if err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	s, published := runScheduler(t, db)
+
+	due := time.Now().Add(100 * time.Millisecond)
+	chirp, err := db.CreateChirp(database.Chirp{
+		AuthorID:  author.ID,
+		Body:      "soon",
+		Status:    database.ChirpScheduled,
+		PublishAt: &due,
+	})
+	if err != nil {
+		t.Fatalf("CreateChirp: %v", err)
+	}
+	// Without Wake the scheduler would sleep for maxSleep.
+	s.Wake()
+	waitForPublish(t, published, chirp.ID)
+}
@@ -0,0 +1,231 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sync"
+)
+
+// formatVersion changes whenever Tokenize does, so that indexes saved with
+// the old terms are rebuilt rather than silently missing matches.
+const formatVersion = 2
+
+// ErrOutdated is returned by Load for an index saved by an older version.
+var ErrOutdated = errors.New("Search index was built by an older version")
+
+// BM25 parameters, at their usual defaults.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Index is an inverted index from terms to the documents and positions they
+// occur at. It is safe for concurrent use.
+type Index struct {
+	mux sync.RWMutex
+	// saveMux keeps concurrent saves from sharing the temporary file.
+	saveMux sync.Mutex
+	Version int `json:"version"`
+	// Postings maps a term to the positions it occurs at in each document.
+	Postings map[string]map[int][]int `json:"postings"`
+	// DocTerms lists the distinct terms of each document, so it can be
+	// removed without scanning every posting list.
+	DocTerms    map[int][]string `json:"doc_terms"`
+	DocLengths  map[int]int      `json:"doc_lengths"`
+	TotalLength int              `json:"total_length"`
+}
+
+// Result is a matching document and its BM25 score.
+type Result struct {
+	ID    int
+	Score float64
+}
+
+func NewIndex() *Index {
+	return &Index{
+		Version:    formatVersion,
+		Postings:   make(map[string]map[int][]int),
+		DocTerms:   make(map[int][]string),
+		DocLengths: make(map[int]int),
+	}
+}
+
+// Load reads an index written by Save. Indexes written by an older version
+// give ErrOutdated and need rebuilding.
+func Load(path string) (*Index, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := NewIndex()
+	idx.Version = 0
+	err = json.Unmarshal(file, idx)
+	if err != nil {
+		return nil, err
+	}
+	if idx.Version != formatVersion {
+		return nil, ErrOutdated
+	}
+	return idx, nil
+}
+
+func (idx *Index) Save(path string) error {
+	idx.saveMux.Lock()
+	defer idx.saveMux.Unlock()
+	idx.mux.RLock()
+	dat, err := json.Marshal(idx)
+	idx.mux.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	err = os.WriteFile(tmpPath, dat, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Add indexes text as document id, replacing whatever was indexed for it.
+func (idx *Index) Add(id int, text string) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	idx.remove(id)
+
+	tokens := Tokenize(text)
+	for _, token := range tokens {
+		docs, ok := idx.Postings[token.Term]
+		if !ok {
+			docs = make(map[int][]int)
+			idx.Postings[token.Term] = docs
+		}
+		if len(docs[id]) == 0 {
+			idx.DocTerms[id] = append(idx.DocTerms[id], token.Term)
+		}
+		docs[id] = append(docs[id], token.Position)
+	}
+	idx.DocLengths[id] = len(tokens)
+	idx.TotalLength += len(tokens)
+}
+
+// Len returns the number of documents in the index.
+func (idx *Index) Len() int {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+	return len(idx.DocLengths)
+}
+
+func (idx *Index) Remove(id int) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id int) {
+	length, ok := idx.DocLengths[id]
+	if !ok {
+		return
+	}
+	for _, term := range idx.DocTerms[id] {
+		delete(idx.Postings[term], id)
+		if len(idx.Postings[term]) == 0 {
+			delete(idx.Postings, term)
+		}
+	}
+	delete(idx.DocTerms, id)
+	delete(idx.DocLengths, id)
+	idx.TotalLength -= length
+}
+
+// Search returns every document containing all of the query's terms and
+// phrases, scored with BM25 over all of those terms. The operators in q are
+// not applied here. The results are in no particular order.
+func (idx *Index) Search(q Query) []Result {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	terms := append([]string{}, q.Terms...)
+	for _, phrase := range q.Phrases {
+		terms = append(terms, phrase...)
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// Start from the rarest term so the candidate set is as small as possible.
+	rarest := terms[0]
+	for _, term := range terms[1:] {
+		if len(idx.Postings[term]) < len(idx.Postings[rarest]) {
+			rarest = term
+		}
+	}
+
+	results := make([]Result, 0)
+	for id := range idx.Postings[rarest] {
+		if !idx.containsAll(id, terms) || !idx.containsPhrases(id, q.Phrases) {
+			continue
+		}
+		results = append(results, Result{ID: id, Score: idx.score(id, terms)})
+	}
+	return results
+}
+
+func (idx *Index) containsAll(id int, terms []string) bool {
+	for _, term := range terms {
+		if len(idx.Postings[term][id]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *Index) containsPhrases(id int, phrases [][]string) bool {
+	for _, phrase := range phrases {
+		if !idx.containsPhrase(id, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *Index) containsPhrase(id int, phrase []string) bool {
+	for _, start := range idx.Postings[phrase[0]][id] {
+		matched := true
+		for offset, term := range phrase[1:] {
+			if !containsInt(idx.Postings[term][id], start+offset+1) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *Index) score(id int, terms []string) float64 {
+	docCount := float64(len(idx.DocLengths))
+	avgLength := float64(idx.TotalLength) / docCount
+	docLength := float64(idx.DocLengths[id])
+
+	score := 0.0
+	for _, term := range terms {
+		docsWithTerm := float64(len(idx.Postings[term]))
+		idf := math.Log(1 + (docCount-docsWithTerm+0.5)/(docsWithTerm+0.5))
+		freq := float64(len(idx.Postings[term][id]))
+		score += idf * freq * (k1 + 1) / (freq + k1*(1-b+b*docLength/avgLength))
+	}
+	return score
+}
+
+func containsInt(values []int, target int) bool {
+	for _, val := range values {
+		if val == target {
+			return true
+		}
+	}
+	return false
+}
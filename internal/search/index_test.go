@@ -0,0 +1,120 @@
+package search
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newTestIndex(docs map[int]string) *Index {
+	idx := NewIndex()
+	for id, text := range docs {
+		idx.Add(id, text)
+	}
+	return idx
+}
+
+func resultIDs(results []Result) []int {
+	ids := make([]int, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestSearchMatches(t *testing.T) {
+	idx := newTestIndex(map[int]string{
+		1: "generic types in Go",
+		2: "types are generic in Go",
+		3: "Go is fun",
+		4: "Straße café",
+	})
+
+	tests := []struct {
+		name string
+		q    string
+		want []int
+	}{
+		{"every word must appear", "go generic", []int{1, 2}},
+		{"phrase must be consecutive", `"generic types"`, []int{1}},
+		{"phrase in the other order", `"types are generic"`, []int{2}},
+		{"phrase and word together", `"generic types" fun`, []int{}},
+		{"unknown word", "rust", []int{}},
+		{"folded query matches", "STRASSE", []int{4}},
+		{"decomposed query matches", "cafe\u0301", []int{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resultIDs(idx.Search(ParseQuery(tt.q)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchRanksWithBM25(t *testing.T) {
+	idx := newTestIndex(map[int]string{
+		1: "go",
+		2: "go go go",
+		3: "go and a lot of other words around it",
+		4: "rust",
+	})
+
+	results := idx.Search(ParseQuery("go"))
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	got := make([]int, len(results))
+	for i, result := range results {
+		got[i] = result.ID
+	}
+	// More occurrences score higher, and so do shorter chirps.
+	want := []int{2, 1, 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ranking = %v, want %v", got, want)
+	}
+}
+
+func TestRemoveAndReAdd(t *testing.T) {
+	idx := newTestIndex(map[int]string{1: "old words", 2: "other"})
+	idx.Add(1, "new words")
+
+	if got := idx.Search(ParseQuery("old")); len(got) != 0 {
+		t.Errorf("Search(old) after re-adding = %v, want none", got)
+	}
+	if got := resultIDs(idx.Search(ParseQuery("new"))); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Search(new) = %v, want [1]", got)
+	}
+	idx.Remove(1)
+	if idx.Len() != 1 || idx.TotalLength != 1 {
+		t.Errorf("after Remove, Len = %d and TotalLength = %d, want 1 and 1", idx.Len(), idx.TotalLength)
+	}
+}
+
+func TestLoadRejectsOutdatedIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.json")
+
+	idx := newTestIndex(map[int]string{1: "saved"})
+	if err := idx.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := resultIDs(loaded.Search(ParseQuery("saved"))); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Search on the loaded index = %v, want [1]", got)
+	}
+
+	err = os.WriteFile(path, []byte(`{"postings":{},"doc_terms":{},"doc_lengths":{}}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !errors.Is(err, ErrOutdated) {
+		t.Errorf("Load of an index without a version error = %v, want ErrOutdated", err)
+	}
+}
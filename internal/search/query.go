@@ -0,0 +1,69 @@
+package search
+
+import (
+	"strings"
+)
+
+// Query is a parsed search string. Bare words must all appear somewhere in
+// a chirp, quoted phrases must appear as consecutive terms, and the
+// operators narrow the results to an author or to hashtags.
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+	// From is the handle given with from:@handle.
+	From string
+	// Tags are the hashtags given with #tag, without the #.
+	Tags []string
+}
+
+// ParseQuery reads a search string such as
+//
+//	go "generic types" from:@alice #release
+func ParseQuery(q string) Query {
+	query := Query{}
+	for len(q) > 0 {
+		q = strings.TrimLeft(q, " \t\n")
+		if q == "" {
+			break
+		}
+
+		if q[0] == '"' {
+			phrase, rest, _ := strings.Cut(q[1:], `"`)
+			query.addText(phrase)
+			q = rest
+			continue
+		}
+
+		word, rest, _ := strings.Cut(q, " ")
+		q = rest
+		switch {
+		case strings.HasPrefix(word, "from:"):
+			query.From = strings.TrimPrefix(strings.TrimPrefix(word, "from:"), "@")
+		case strings.HasPrefix(word, "#") && len(word) > 1:
+			query.Tags = append(query.Tags, word[1:])
+		default:
+			query.addText(word)
+		}
+	}
+	return query
+}
+
+// HasText reports whether the query matches on chirp text rather than only
+// on its operators.
+func (q Query) HasText() bool {
+	return len(q.Terms) > 0 || len(q.Phrases) > 0
+}
+
+// addText adds a bare word or quoted phrase. A single word can tokenize to
+// several terms, such as "e-mail" or a run of Han characters, and is then
+// treated as a phrase.
+func (q *Query) addText(text string) {
+	terms := Terms(text)
+	switch len(terms) {
+	case 0:
+	case 1:
+		q.Terms = append(q.Terms, terms[0])
+	default:
+		q.Phrases = append(q.Phrases, terms)
+	}
+}
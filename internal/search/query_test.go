@@ -0,0 +1,72 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want Query
+	}{
+		{
+			name: "bare words",
+			q:    "Go Generics",
+			want: Query{Terms: []string{"go", "generics"}},
+		},
+		{
+			name: "quoted phrase",
+			q:    `"generic types" release`,
+			want: Query{Terms: []string{"release"}, Phrases: [][]string{{"generic", "types"}}},
+		},
+		{
+			name: "unterminated phrase runs to the end",
+			q:    `"generic types`,
+			want: Query{Phrases: [][]string{{"generic", "types"}}},
+		},
+		{
+			name: "word that tokenizes to several terms is a phrase",
+			q:    "e-mail",
+			want: Query{Phrases: [][]string{{"e", "mail"}}},
+		},
+		{
+			name: "operators",
+			q:    "from:@alice #release #go",
+			want: Query{From: "alice", Tags: []string{"release", "go"}},
+		},
+		{
+			name: "from without the at sign",
+			q:    "from:alice",
+			want: Query{From: "alice"},
+		},
+		{
+			name: "normalizes like the index",
+			q:    "CAFÉ",
+			want: Query{Terms: []string{"café"}},
+		},
+		{
+			name: "lone hash is text",
+			q:    "#",
+			want: Query{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseQuery(tt.q)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryHasText(t *testing.T) {
+	if ParseQuery("from:@alice #go").HasText() {
+		t.Error("operator-only query reports text")
+	}
+	if !ParseQuery("go").HasText() || !ParseQuery(`"go now"`).HasText() {
+		t.Error("query with words or phrases reports no text")
+	}
+}
@@ -0,0 +1,66 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Token is a normalized term and its position in the tokenized text.
+type Token struct {
+	Term     string
+	Position int
+}
+
+// Tokenize splits text into case-folded terms in NFC, the form chirp bodies
+// are stored in, so that "STRASSE" matches "straße" and a decomposed "é"
+// matches a precomposed one. Words are runs of letters, digits and combining
+// marks. Scripts written without spaces between words (Han, Hiragana and
+// Katakana) produce one term per character so that phrase queries can match
+// inside a run of them.
+func Tokenize(text string) []Token {
+	tokens := make([]Token, 0)
+	// Casers keep state, so each call gets its own.
+	fold := cases.Fold()
+	var word strings.Builder
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		term := norm.NFC.String(fold.String(word.String()))
+		tokens = append(tokens, Token{Term: term, Position: len(tokens)})
+		word.Reset()
+	}
+
+	for _, r := range norm.NFC.String(text) {
+		switch {
+		case isIdeographic(r):
+			flush()
+			tokens = append(tokens, Token{Term: string(r), Position: len(tokens)})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		case unicode.In(r, unicode.Mn, unicode.Mc) && word.Len() > 0:
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// Terms returns just the terms of Tokenize(text).
+func Terms(text string) []string {
+	tokens := Tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return terms
+}
+
+func isIdeographic(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
@@ -0,0 +1,42 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"lower-cases words", "Hello World", []string{"hello", "world"}},
+		{"splits on punctuation", "e-mail, please!", []string{"e", "mail", "please"}},
+		{"keeps digits", "Go 1.21 is out", []string{"go", "1", "21", "is", "out"}},
+		{"folds case beyond lower-casing", "STRASSE straße", []string{"strasse", "strasse"}},
+		{"composes decomposed letters", "cafe\u0301 café", []string{"café", "café"}},
+		{"keeps combining marks inside words", "हिन्दी", []string{"हिन्दी"}},
+		{"splits Han into characters", "日本語", []string{"日", "本", "語"}},
+		{"separates Han from Latin words", "go言語", []string{"go", "言", "語"}},
+		{"ignores emoji", "🎉 party 🎉", []string{"party"}},
+		{"empty text", "", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Terms(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	tokens := Tokenize("one, two  three")
+	for i, token := range tokens {
+		if token.Position != i {
+			t.Errorf("token %q at position %d, want %d", token.Term, token.Position, i)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package trends
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+)
+
+var ErrUnknownWindow = errors.New("Unknown trends window")
+
+// maxResults caps how many hashtags and chirps each window keeps.
+const maxResults = 50
+
+// Clock tells the aggregator what time it is, so that tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by time.Now.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// Source provides the activity trends are computed from. *database.DB
+// implements it.
+type Source interface {
+	ActivitySince(since time.Time) ([]database.Activity, error)
+}
+
+// Window is a period trends are computed over. Activity loses half of its
+// weight every HalfLife, so recent activity counts for more.
+type Window struct {
+	Name     string
+	Length   time.Duration
+	HalfLife time.Duration
+}
+
+var DefaultWindows = []Window{
+	{Name: "1h", Length: time.Hour, HalfLife: 15 * time.Minute},
+	{Name: "24h", Length: 24 * time.Hour, HalfLife: 6 * time.Hour},
+}
+
+// activityWeights is how much each kind of activity contributes to a chirp's
+// score. Posts only count towards hashtags.
+var activityWeights = map[string]float64{
+	database.ActivityLike:    1,
+	database.ActivityRechirp: 2,
+	database.ActivityReply:   2,
+	database.ActivityQuote:   3,
+}
+
+type TagTrend struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+	Uses  int     `json:"uses"`
+}
+
+type ChirpTrend struct {
+	ChirpID     int     `json:"chirp_id"`
+	Score       float64 `json:"score"`
+	Engagements int     `json:"engagements"`
+}
+
+// Snapshot is the trends for one window as of ComputedAt.
+type Snapshot struct {
+	Window     string       `json:"window"`
+	ComputedAt time.Time    `json:"computed_at"`
+	Tags       []TagTrend   `json:"hashtags"`
+	Chirps     []ChirpTrend `json:"chirps"`
+}
+
+// Aggregator periodically computes trends from a Source and caches them
+// until the next refresh.
+type Aggregator struct {
+	source    Source
+	clock     Clock
+	windows   []Window
+	mux       sync.RWMutex
+	snapshots map[string]Snapshot
+}
+
+func NewAggregator(source Source, clock Clock, windows []Window) *Aggregator {
+	return &Aggregator{
+		source:    source,
+		clock:     clock,
+		windows:   windows,
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+// Run refreshes the trends every interval until stop is closed. It refreshes
+// once straight away.
+func (a *Aggregator) Run(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := a.Refresh(); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh recomputes every window as of the clock's current time.
+func (a *Aggregator) Refresh() error {
+	now := a.clock.Now()
+	longest := time.Duration(0)
+	for _, window := range a.windows {
+		if window.Length > longest {
+			longest = window.Length
+		}
+	}
+
+	activity, err := a.source.ActivitySince(now.Add(-longest))
+	if err != nil {
+		return err
+	}
+
+	snapshots := make(map[string]Snapshot, len(a.windows))
+	for _, window := range a.windows {
+		snapshots[window.Name] = Compute(activity, window, now)
+	}
+
+	a.mux.Lock()
+	a.snapshots = snapshots
+	a.mux.Unlock()
+	return nil
+}
+
+// Trends returns the cached snapshot for the named window. The snapshot is
+// empty until the first refresh.
+func (a *Aggregator) Trends(window string) (Snapshot, error) {
+	for _, w := range a.windows {
+		if w.Name != window {
+			continue
+		}
+		a.mux.RLock()
+		snapshot, ok := a.snapshots[window]
+		a.mux.RUnlock()
+		if !ok {
+			snapshot = Snapshot{Window: window, Tags: make([]TagTrend, 0), Chirps: make([]ChirpTrend, 0)}
+		}
+		return snapshot, nil
+	}
+	return Snapshot{}, ErrUnknownWindow
+}
+
+// Compute scores the activity within window as of now. The result depends
+// only on its arguments: ties are broken by tag name and by newest chirp.
+func Compute(activity []database.Activity, window Window, now time.Time) Snapshot {
+	start := now.Add(-window.Length)
+	tags := make(map[string]*TagTrend)
+	chirps := make(map[int]*ChirpTrend)
+
+	for _, entry := range activity {
+		if entry.At.Before(start) || entry.At.After(now) {
+			continue
+		}
+		weight := decay(now.Sub(entry.At), window.HalfLife)
+
+		if entry.Kind == database.ActivityPost {
+			for _, tag := range entry.Tags {
+				trend, ok := tags[tag]
+				if !ok {
+					trend = &TagTrend{Tag: tag}
+					tags[tag] = trend
+				}
+				trend.Score += weight
+				trend.Uses++
+			}
+			continue
+		}
+
+		trend, ok := chirps[entry.ChirpID]
+		if !ok {
+			trend = &ChirpTrend{ChirpID: entry.ChirpID}
+			chirps[entry.ChirpID] = trend
+		}
+		trend.Score += weight * activityWeights[entry.Kind]
+		trend.Engagements++
+	}
+
+	snapshot := Snapshot{
+		Window:     window.Name,
+		ComputedAt: now,
+		Tags:       make([]TagTrend, 0, len(tags)),
+		Chirps:     make([]ChirpTrend, 0, len(chirps)),
+	}
+	for _, trend := range tags {
+		trend.Score = round(trend.Score)
+		snapshot.Tags = append(snapshot.Tags, *trend)
+	}
+	for _, trend := range chirps {
+		trend.Score = round(trend.Score)
+		snapshot.Chirps = append(snapshot.Chirps, *trend)
+	}
+
+	sort.Slice(snapshot.Tags, func(i, j int) bool {
+		a, b := snapshot.Tags[i], snapshot.Tags[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Tag < b.Tag
+	})
+	sort.Slice(snapshot.Chirps, func(i, j int) bool {
+		a, b := snapshot.Chirps[i], snapshot.Chirps[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.ChirpID > b.ChirpID
+	})
+	if len(snapshot.Tags) > maxResults {
+		snapshot.Tags = snapshot.Tags[:maxResults]
+	}
+	if len(snapshot.Chirps) > maxResults {
+		snapshot.Chirps = snapshot.Chirps[:maxResults]
+	}
+	return snapshot
+}
+
+func decay(age, halfLife time.Duration) float64 {
+	return math.Exp2(-float64(age) / float64(halfLife))
+}
+
+// round keeps scores to four decimal places, which is all the precision
+// they need and keeps them stable against summation order.
+func round(score float64) float64 {
+	return math.Round(score*1e4) / 1e4
+}
@@ -0,0 +1,163 @@
+package trends
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeSource serves fixed activity and records what it was asked for.
+type fakeSource struct {
+	activity []database.Activity
+	since    time.Time
+}
+
+func (s *fakeSource) ActivitySince(since time.Time) ([]database.Activity, error) {
+	s.since = since
+	filtered := make([]database.Activity, 0)
+	for _, entry := range s.activity {
+		if !entry.At.Before(since) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestCompute(t *testing.T) {
+	activity := []database.Activity{
+		{Kind: database.ActivityPost, ChirpID: 1, Tags: []string{"go", "chirpy"}, At: start},
+		{Kind: database.ActivityPost, ChirpID: 2, Tags: []string{"go"}, At: start.Add(-15 * time.Minute)},
+		{Kind: database.ActivityLike, ChirpID: 1, At: start},
+		{Kind: database.ActivityRechirp, ChirpID: 1, At: start.Add(-15 * time.Minute)},
+		{Kind: database.ActivityReply, ChirpID: 2, At: start.Add(-30 * time.Minute)},
+		{Kind: database.ActivityQuote, ChirpID: 3, At: start.Add(-2 * time.Hour)},
+		// Activity after now hasn't happened yet as far as the window goes.
+		{Kind: database.ActivityLike, ChirpID: 4, At: start.Add(time.Minute)},
+	}
+
+	tests := []struct {
+		window     Window
+		wantTags   []TagTrend
+		wantChirps []ChirpTrend
+	}{
+		{
+			window: DefaultWindows[0],
+			wantTags: []TagTrend{
+				{Tag: "go", Score: 1.5, Uses: 2},
+				{Tag: "chirpy", Score: 1, Uses: 1},
+			},
+			wantChirps: []ChirpTrend{
+				{ChirpID: 1, Score: 2, Engagements: 2},
+				{ChirpID: 2, Score: 0.5, Engagements: 1},
+			},
+		},
+		{
+			window: DefaultWindows[1],
+			wantTags: []TagTrend{
+				{Tag: "go", Score: 1.9715, Uses: 2},
+				{Tag: "chirpy", Score: 1, Uses: 1},
+			},
+			wantChirps: []ChirpTrend{
+				{ChirpID: 1, Score: 2.9431, Engagements: 2},
+				{ChirpID: 3, Score: 2.3811, Engagements: 1},
+				{ChirpID: 2, Score: 1.8877, Engagements: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window.Name, func(t *testing.T) {
+			snapshot := Compute(activity, tt.window, start)
+			if snapshot.Window != tt.window.Name || !snapshot.ComputedAt.Equal(start) {
+				t.Errorf("snapshot is for %q at %v", snapshot.Window, snapshot.ComputedAt)
+			}
+			if !reflect.DeepEqual(snapshot.Tags, tt.wantTags) {
+				t.Errorf("tags = %+v, want %+v", snapshot.Tags, tt.wantTags)
+			}
+			if !reflect.DeepEqual(snapshot.Chirps, tt.wantChirps) {
+				t.Errorf("chirps = %+v, want %+v", snapshot.Chirps, tt.wantChirps)
+			}
+		})
+	}
+}
+
+func TestComputeBreaksTiesDeterministically(t *testing.T) {
+	activity := []database.Activity{
+		{Kind: database.ActivityPost, ChirpID: 1, Tags: []string{"zebra", "apple"}, At: start},
+		{Kind: database.ActivityLike, ChirpID: 1, At: start},
+		{Kind: database.ActivityLike, ChirpID: 2, At: start},
+	}
+	for i := 0; i < 10; i++ {
+		snapshot := Compute(activity, DefaultWindows[0], start)
+		if snapshot.Tags[0].Tag != "apple" || snapshot.Tags[1].Tag != "zebra" {
+			t.Fatalf("tags = %+v, want apple before zebra", snapshot.Tags)
+		}
+		if snapshot.Chirps[0].ChirpID != 2 || snapshot.Chirps[1].ChirpID != 1 {
+			t.Fatalf("chirps = %+v, want the newest chirp first", snapshot.Chirps)
+		}
+	}
+}
+
+func TestAggregatorFollowsClock(t *testing.T) {
+	clock := &fakeClock{now: start}
+	source := &fakeSource{activity: []database.Activity{
+		{Kind: database.ActivityLike, ChirpID: 1, At: start},
+	}}
+	aggregator := NewAggregator(source, clock, DefaultWindows)
+
+	empty, err := aggregator.Trends("1h")
+	if err != nil {
+		t.Fatalf("Trends before refresh: %v", err)
+	}
+	if len(empty.Chirps) != 0 || len(empty.Tags) != 0 {
+		t.Errorf("trends before refresh = %+v, want empty", empty)
+	}
+
+	steps := []struct {
+		advance time.Duration
+		want1h  []ChirpTrend
+		want24h []ChirpTrend
+	}{
+		{0, []ChirpTrend{{ChirpID: 1, Score: 1, Engagements: 1}}, []ChirpTrend{{ChirpID: 1, Score: 1, Engagements: 1}}},
+		{30 * time.Minute, []ChirpTrend{{ChirpID: 1, Score: 0.25, Engagements: 1}}, []ChirpTrend{{ChirpID: 1, Score: 0.9439, Engagements: 1}}},
+		{31 * time.Minute, []ChirpTrend{}, []ChirpTrend{{ChirpID: 1, Score: 0.8892, Engagements: 1}}},
+		{23 * time.Hour, []ChirpTrend{}, []ChirpTrend{}},
+	}
+	for _, step := range steps {
+		clock.now = clock.now.Add(step.advance)
+		if err := aggregator.Refresh(); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		if want := clock.now.Add(-24 * time.Hour); !source.since.Equal(want) {
+			t.Errorf("at %v: activity read since %v, want %v", clock.now, source.since, want)
+		}
+		for window, want := range map[string][]ChirpTrend{"1h": step.want1h, "24h": step.want24h} {
+			snapshot, err := aggregator.Trends(window)
+			if err != nil {
+				t.Fatalf("Trends(%s): %v", window, err)
+			}
+			if !snapshot.ComputedAt.Equal(clock.now) {
+				t.Errorf("%s computed at %v, want %v", window, snapshot.ComputedAt, clock.now)
+			}
+			if !reflect.DeepEqual(snapshot.Chirps, want) {
+				t.Errorf("at %v: %s chirps = %+v, want %+v", clock.now, window, snapshot.Chirps, want)
+			}
+		}
+	}
+
+	if _, err := aggregator.Trends("7d"); !errors.Is(err, ErrUnknownWindow) {
+		t.Errorf("Trends(7d) error = %v, want ErrUnknownWindow", err)
+	}
+}
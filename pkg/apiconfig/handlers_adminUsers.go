@@ -0,0 +1,263 @@
+package apiconfig
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/audit"
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// GetUsersHandler lists users in ID order, filtered by email, handle, tier
+// and role.
+func (cfg *ApiConfig) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.confirmRole(w, r, database.RoleAdmin); !ok {
+		return
+	}
+
+	values := r.URL.Query()
+	query := database.UserQuery{
+		Email:  values.Get("email"),
+		Handle: values.Get("handle"),
+		Tier:   values.Get("tier"),
+		Role:   values.Get("role"),
+	}
+
+	var err error
+	query.Limit, err = parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	scope := fmt.Sprintf("users:%s:%s:%s:%s", query.Email, query.Handle, query.Tier, query.Role)
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	if after != nil {
+		query.AfterID = after.ID
+	}
+
+	users, hasMore, err := cfg.Database.SearchUsers(query)
+	if errors.Is(err, database.ErrInvalidTier) || errors.Is(err, database.ErrInvalidRole) {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type userPage struct {
+		Users      []database.User `json:"users"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+	}
+	res := userPage{Users: users}
+	if hasMore {
+		last := users[len(users)-1]
+		res.NextCursor, err = cfg.nextCursor(scope, &database.Position{ID: last.ID})
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.manageUser(w, r, func(adminID, userID int) (interface{}, error) {
+		return cfg.Database.GetUser(userID)
+	})
+}
+
+func (cfg *ApiConfig) GetUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.manageUser(w, r, func(adminID, userID int) (interface{}, error) {
+		return cfg.Database.GetUserSessions(userID)
+	})
+}
+
+// RevokeUserSessionsHandler logs a user out everywhere. Their access tokens
+// stop working straight away.
+func (cfg *ApiConfig) RevokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Revoked int `json:"revoked"`
+	}
+
+	cfg.manageUser(w, r, func(adminID, userID int) (interface{}, error) {
+		revoked, err := cfg.Database.RevokeUserSessions(userID)
+		return response{Revoked: revoked}, err
+	})
+}
+
+// RequirePasswordResetHandler logs a user out and makes them change their
+// password the next time they log in.
+func (cfg *ApiConfig) RequirePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.manageUser(w, r, func(adminID, userID int) (interface{}, error) {
+		return cfg.Database.RequirePasswordReset(userID)
+	})
+}
+
+// SetChirpyRedHandler turns Chirpy Red on or off for a user by hand, as the
+// Polka webhook would.
+func (cfg *ApiConfig) SetChirpyRedHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	cfg.manageUser(w, r, func(adminID, userID int) (interface{}, error) {
+		if _, err := cfg.Database.GetUser(userID); err != nil {
+			return nil, err
+		}
+		var err error
+		if params.Enabled {
+			err = cfg.Database.UpgradeUser(userID)
+		} else {
+			err = cfg.Database.DowngradeUser(userID, cfg.PinLimit)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return cfg.Database.GetUser(userID)
+	})
+}
+
+// ImpersonateUserHandler gives an admin an access token that acts as the
+// user, for debugging. It can't be refreshed or change the user's
+// credentials, shows up among the user's sessions and everything done with
+// it is marked in the audit log.
+func (cfg *ApiConfig) ImpersonateUserHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Token     string    `json:"token"`
+		SessionID string    `json:"session_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	cfg.manageUser(w, r, func(adminID, userID int) (interface{}, error) {
+		user, err := cfg.Database.GetUser(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		sessionID, err := newSessionID()
+		if err != nil {
+			return nil, err
+		}
+		session, err := cfg.Database.CreateSession(database.Session{
+			ID:             sessionID,
+			UserID:         userID,
+			ImpersonatorID: adminID,
+			IP:             r.RemoteAddr,
+			UserAgent:      r.UserAgent(),
+			ExpiresAt:      time.Now().Add(accessTokenLifetime).UTC(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		token, err := generateJwtToken(userID, "chirpy-access", cfg.JwtSecret, tokenClaims{
+			Role:           user.Role,
+			SessionID:      session.ID,
+			ImpersonatorID: adminID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cfg.recordAudit(r, audit.Entry{
+			Event:          audit.EventImpersonationStarted,
+			ActorID:        adminID,
+			ImpersonatorID: adminID,
+			SubjectID:      userID,
+			Details:        map[string]string{"session_id": session.ID},
+		})
+		return response{Token: token, SessionID: session.ID, ExpiresAt: session.ExpiresAt}, nil
+	})
+}
+
+// manageUser runs an admin action against the user in the URL, responding
+// with what it returns.
+func (cfg *ApiConfig) manageUser(
+	w http.ResponseWriter,
+	r *http.Request,
+	action func(adminID, userID int) (interface{}, error),
+) {
+	adminID, ok := cfg.confirmRole(w, r, database.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "User ID must be an integer")
+		return
+	}
+
+	res, err := action(adminID, userID)
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrImpersonateAdmin):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+// impersonatorID returns the admin behind the request's access token when it
+// is an impersonation token, or 0. The token is only parsed, not checked
+// against its session.
+func (cfg *ApiConfig) impersonatorID(r *http.Request) int {
+	tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return 0
+	}
+	claims := tokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JwtSecret), nil
+	})
+	if err != nil {
+		return 0
+	}
+	return claims.ImpersonatorID
+}
+
+// impersonating reports whether claims belong to an impersonation token.
+// Those can't change the user's credentials, so that an admin debugging as
+// a user can't take their account over.
+func impersonating(claims jwt.MapClaims) bool {
+	_, ok := claims["imp"]
+	return ok
+}
+
+func newSessionID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -6,14 +6,18 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/text/unicode/norm"
 
+	"github.com/AxterDoesCode/webserver/internal/audit"
+	"github.com/AxterDoesCode/webserver/internal/chirptext"
+	"github.com/AxterDoesCode/webserver/internal/contentfilter"
+	"github.com/AxterDoesCode/webserver/internal/database"
 	"github.com/AxterDoesCode/webserver/pkg/httphandler"
 )
 
@@ -52,7 +56,7 @@ func (cfg *ApiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.Database.GetChirpByID(id)
+	chirp, err := cfg.Database.GetChirpByID(id, cfg.viewerID(r))
 	if err != nil {
 		httphandler.RespondWithError(w, 404, "Chirp Doesn't exist")
 		return
@@ -64,57 +68,47 @@ func (cfg *ApiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 func (cfg *ApiConfig) DeleteChirpByID(w http.ResponseWriter, r *http.Request) {
 	param := chi.URLParam(r, "chirpID")
 
-	tokenString := r.Header.Get("Authorization")
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-	claims := jwt.MapClaims{}
-	jwtToken, err := jwt.ParseWithClaims(
-		tokenString,
-		claims,
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JwtSecret), nil
-		},
-	)
-	if err != nil {
-		httphandler.RespondWithError(w, http.StatusUnauthorized, "Token is invalid")
-		return
-	}
-
-	tokenIssuer, err := jwtToken.Claims.GetIssuer()
+	userID, err := cfg.authenticateAccessToken(r)
 	if err != nil {
-		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
 		return
 	}
 
-	if tokenIssuer != "chirpy-access" {
-		httphandler.RespondWithError(w, http.StatusUnauthorized, "Token is not a refresh token")
-		return
-	}
-
-	tokenSubject, err := jwtToken.Claims.GetSubject()
+	paramID, err := strconv.Atoi(param)
 	if err != nil {
-		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
-		return
-	}
-
-	if param != tokenSubject {
 		httphandler.RespondWithError(
 			w,
-			http.StatusForbidden,
-			"Cannot Delete: Chirp does not belong to user",
+			http.StatusInternalServerError,
+			"error converting id to int",
 		)
 		return
 	}
 
-	paramID, err := strconv.Atoi(param)
-	if err != nil {
+	deletedChirp, err := cfg.Database.DeleteChirpByID(paramID, userID)
+	switch {
+	case errors.Is(err, database.ErrNotChirpAuthor):
 		httphandler.RespondWithError(
 			w,
-			http.StatusInternalServerError,
-			"error converting id to int",
+			http.StatusForbidden,
+			"Cannot Delete: Chirp does not belong to user",
 		)
 		return
+	case errors.Is(err, database.ErrChirpHidden):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
 	}
-	deletedChirp, err := cfg.Database.DeleteChirpByID(paramID)
+	cfg.recordAudit(r, audit.Entry{
+		Event:     audit.EventChirpDeleted,
+		ActorID:   userID,
+		SubjectID: userID,
+		Details:   map[string]string{"chirp_id": strconv.Itoa(deletedChirp.ID)},
+	})
 	httphandler.RespondWithJSON(w, http.StatusOK, deletedChirp)
 }
 
@@ -122,6 +116,7 @@ func (cfg *ApiConfig) AddUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
+		Handle   string `json:"handle"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -137,7 +132,11 @@ func (cfg *ApiConfig) AddUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.Database.AddUser(params.Password, params.Email)
+	user, err := cfg.Database.AddUser(params.Password, params.Email, params.Handle)
+	if errors.Is(err, database.ErrInvalidHandle) || errors.Is(err, database.ErrHandleTaken) {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
 		return
@@ -147,14 +146,34 @@ func (cfg *ApiConfig) AddUser(w http.ResponseWriter, r *http.Request) {
 
 func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 	authorID := r.URL.Query().Get("author_id")
-	sortingMethod := r.URL.Query().Get("sort")
-	slice, err := cfg.Database.GetChirpsArr()
+	sortField, sortDesc, err := parseChirpSort(r.URL.Query().Get("sort"))
 	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
 		return
 	}
 
+	since, err := parseTimeParam(r, "since")
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	until, err := parseTimeParam(r, "until")
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	query := database.ChirpQuery{
+		ViewerID:  cfg.viewerID(r),
+		SortField: sortField,
+		SortDesc:  sortDesc,
+		Since:     since,
+		Until:     until,
+	}
+
 	if authorID != "" {
-		targetAuthorID, err := strconv.Atoi(authorID)
+		query.PinnedFirst = true
+		query.AuthorID, err = strconv.Atoi(authorID)
 		if err != nil {
 			httphandler.RespondWithError(
 				w,
@@ -163,48 +182,133 @@ func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 			)
 			return
 		}
-		slice, err = cfg.Database.GetChirpsByAuthor(targetAuthorID)
 	}
-	if sortingMethod == "desc" {
-		sort.Slice(slice, func(i, j int) bool {
-			return slice[i].ID > slice[j].ID
-		})
-	} else {
-		sort.Slice(slice, func(i, j int) bool {
-			return slice[i].ID < slice[j].ID
-		})
-	}
-	httphandler.RespondWithJSON(w, http.StatusOK, slice)
+
+	// Every listing is paged. The scope covers the filters as well as the
+	// order, so a cursor can't be replayed with a different time range.
+	scope := fmt.Sprintf(
+		"chirps:%s:%t:%d:%s:%s",
+		sortField,
+		sortDesc,
+		query.AuthorID,
+		timeScope(since),
+		timeScope(until),
+	)
+	cfg.respondWithChirpPage(w, r, query, scope)
 }
 
-func (cfg *ApiConfig) PostChirp(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body string `json:"body"`
+// timeScope formats an optional time bound for a cursor scope.
+func timeScope(t time.Time) string {
+	if t.IsZero() {
+		return ""
 	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
 
-	tokenString := r.Header.Get("Authorization")
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-	claims := jwt.MapClaims{}
-	jwtToken, err := jwt.ParseWithClaims(
-		tokenString,
-		claims,
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JwtSecret), nil
-		},
-	)
+// respondWithChirpPage answers query one page at a time, reading limit and
+// cursor from the request. scope must identify the listing and its order.
+func (cfg *ApiConfig) respondWithChirpPage(
+	w http.ResponseWriter,
+	r *http.Request,
+	query database.ChirpQuery,
+	scope string,
+) {
+	var err error
+	query.Limit, err = parseLimit(r)
 	if err != nil {
-		httphandler.RespondWithError(w, http.StatusUnauthorized, "Token is invalid")
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	query.After, err = cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
 		return
 	}
 
-	tokenIssuer, err := jwtToken.Claims.GetIssuer()
+	chirps, hasMore, err := cfg.Database.QueryChirps(query)
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
 		return
 	}
 
-	if tokenIssuer != "chirpy-access" {
-		httphandler.RespondWithError(w, http.StatusUnauthorized, "Token is not an access token")
+	type chirpPage struct {
+		Chirps     []database.Chirp `json:"chirps"`
+		NextCursor string           `json:"next_cursor,omitempty"`
+	}
+	res := chirpPage{Chirps: chirps}
+	if hasMore {
+		last := chirps[len(chirps)-1].Position()
+		res.NextCursor, err = cfg.nextCursor(scope, &last)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+// parseChirpSort accepts the legacy "asc" / "desc" values, which sort by ID,
+// as well as "field:direction" for the id and created_at fields.
+func parseChirpSort(value string) (string, bool, error) {
+	if value == "" {
+		return "id", false, nil
+	}
+
+	field, direction, found := strings.Cut(value, ":")
+	if !found {
+		field, direction = "id", value
+	}
+
+	if field != "id" && field != "created_at" {
+		return "", false, fmt.Errorf("Cannot sort by %q", field)
+	}
+
+	switch direction {
+	case "asc":
+		return field, false, nil
+	case "desc":
+		return field, true, nil
+	default:
+		return "", false, fmt.Errorf("Sort direction must be asc or desc, got %q", direction)
+	}
+}
+
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return parsed, nil
+}
+
+func (cfg *ApiConfig) PostChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body      string   `json:"body"`
+		InReplyTo int      `json:"in_reply_to"`
+		QuoteOf   int      `json:"quote_of"`
+		MediaIDs  []string `json:"media_ids"`
+		// Visibility defaults to public.
+		Visibility     string `json:"visibility"`
+		ContentWarning string `json:"content_warning"`
+		Sensitive      bool   `json:"sensitive"`
+		// PublishAt schedules the chirp instead of publishing it now, and
+		// Draft saves it without publishing it at all.
+		PublishAt *time.Time `json:"publish_at"`
+		Draft     bool       `json:"draft"`
+		Poll      *struct {
+			Options         []string `json:"options"`
+			DurationMinutes int      `json:"duration_minutes"`
+		} `json:"poll"`
+	}
+
+	id, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
 		return
 	}
 
@@ -220,49 +324,189 @@ func (cfg *ApiConfig) PostChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	const maxLength = 140
-	if len(params.Body) > maxLength {
-		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	author, err := cfg.Database.GetUser(id)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+	if author.Suspended(time.Now()) {
+		httphandler.RespondWithError(w, http.StatusForbidden, suspensionMessage(author))
 		return
 	}
 
-	claimSubject, err := jwtToken.Claims.GetSubject()
+	body, flags, err := cfg.prepareChirpBody(params.Body, author)
 	if err != nil {
-		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		respondWithChirpBodyError(w, err)
 		return
 	}
 
-	id, err := strconv.Atoi(claimSubject)
+	contentWarning, err := cfg.prepareContentWarning(params.ContentWarning)
 	if err != nil {
-		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		respondWithChirpBodyError(w, err)
+		return
+	}
+
+	if params.InReplyTo != 0 {
+		_, err = cfg.Database.GetChirpByID(params.InReplyTo, id)
+		if err != nil {
+			httphandler.RespondWithError(
+				w,
+				http.StatusBadRequest,
+				"in_reply_to doesn't correspond to any chirp",
+			)
+			return
+		}
+	}
+
+	if params.QuoteOf != 0 {
+		_, err = cfg.Database.GetChirpByID(params.QuoteOf, id)
+		if err != nil {
+			httphandler.RespondWithError(
+				w,
+				http.StatusBadRequest,
+				"quote_of doesn't correspond to any chirp",
+			)
+			return
+		}
+	}
+
+	newChirp := database.Chirp{
+		AuthorID:       id,
+		Body:           body,
+		InReplyTo:      params.InReplyTo,
+		QuoteOf:        params.QuoteOf,
+		MediaIDs:       params.MediaIDs,
+		Visibility:     params.Visibility,
+		ContentWarning: contentWarning,
+		Sensitive:      params.Sensitive,
+		Status:         database.ChirpPublished,
+	}
+	switch {
+	case params.Draft && params.PublishAt != nil:
+		httphandler.RespondWithError(w, http.StatusBadRequest, "A draft can't have publish_at")
 		return
+	case params.Draft:
+		newChirp.Status = database.ChirpDraft
+	case params.PublishAt != nil:
+		if err := checkPublishAt(*params.PublishAt); err != nil {
+			httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+			return
+		}
+		newChirp.Status = database.ChirpScheduled
+		newChirp.PublishAt = params.PublishAt
 	}
 
-	tempChirp, err := cfg.Database.CreateChirp(id, cleanChirp(params.Body))
+	if params.Poll != nil {
+		duration := time.Duration(params.Poll.DurationMinutes) * time.Minute
+		newChirp.Poll, err = database.NewPoll(params.Poll.Options, duration)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+
+	tempChirp, err := cfg.Database.CreateChirp(newChirp)
+	if errors.Is(err, database.ErrParentNotFound) ||
+		errors.Is(err, database.ErrQuotedNotFound) ||
+		errors.Is(err, database.ErrMediaNotFound) ||
+		errors.Is(err, database.ErrTooManyMedia) ||
+		errors.Is(err, database.ErrInvalidVisibility) {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, "Error creating chirp")
 		log.Print(err)
 		return
 	}
+	cfg.recordContentFlags(tempChirp.ID, flags)
+	if tempChirp.Status == database.ChirpScheduled || tempChirp.Poll != nil {
+		cfg.Scheduler.Wake()
+	}
 	httphandler.RespondWithJSON(w, 201, tempChirp)
 }
 
-func cleanChirp(text string) string {
-	chirpSlice := strings.Split(text, " ")
+// chirpTooLongError reports a chirp body over its author's length limit,
+// both measured with chirptext.Length.
+type chirpTooLongError struct {
+	Length    int
+	MaxLength int
+}
 
-	for i, word := range chirpSlice {
-		loweredWord := strings.ToLower(word)
-		if loweredWord == "kerfuffle" || loweredWord == "sharbert" || loweredWord == "fornax" {
-			chirpSlice[i] = "****"
-		}
+func (e chirpTooLongError) Error() string {
+	return fmt.Sprintf("Chirp is too long: %d characters, the limit is %d", e.Length, e.MaxLength)
+}
+
+// chirpLengthLimit is the longest chirp author may post.
+func (cfg *ApiConfig) chirpLengthLimit(author database.User) int {
+	if author.ChirpyRed {
+		return cfg.ChirpMaxLengthRed
+	}
+	return cfg.ChirpMaxLength
+}
+
+// prepareChirpBody applies the rules every chirp body goes through, on
+// creation as well as on edits. It returns the body after content
+// filtering and any flags the filter raised, which the caller records once
+// the chirp is stored.
+func (cfg *ApiConfig) prepareChirpBody(body string, author database.User) (string, []database.ContentFlag, error) {
+	return cfg.filterBody(body, cfg.chirpLengthLimit(author))
+}
+
+// filterBody normalizes body, checks it against maxLength and runs it
+// through the content filter.
+func (cfg *ApiConfig) filterBody(body string, maxLength int) (string, []database.ContentFlag, error) {
+	body = norm.NFC.String(body)
+	length := chirptext.Length(body)
+	if length > maxLength {
+		return "", nil, chirpTooLongError{Length: length, MaxLength: maxLength}
+	}
+
+	result := contentfilter.Apply(cfg.ContentFilter, body)
+	if result.Rejected {
+		return "", nil, errors.New(result.Reason)
+	}
+	flags := make([]database.ContentFlag, len(result.Flags))
+	for i, flag := range result.Flags {
+		flags[i] = database.ContentFlag{Rule: flag.Rule, Match: flag.Match}
+	}
+	return result.Text, flags, nil
+}
+
+// respondWithChirpBodyError reports why prepareChirpBody refused a body.
+func respondWithChirpBodyError(w http.ResponseWriter, err error) {
+	var tooLong chirpTooLongError
+	if !errors.As(err, &tooLong) {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type tooLongResponse struct {
+		Error     string `json:"error"`
+		Length    int    `json:"length"`
+		MaxLength int    `json:"max_length"`
+	}
+	httphandler.RespondWithJSON(w, http.StatusBadRequest, tooLongResponse{
+		Error:     tooLong.Error(),
+		Length:    tooLong.Length,
+		MaxLength: tooLong.MaxLength,
+	})
+}
+
+// recordContentFlags stores the flags raised for a chirp. The chirp has
+// already been saved, so a failure is only logged.
+func (cfg *ApiConfig) recordContentFlags(chirpID int, flags []database.ContentFlag) {
+	err := cfg.Database.FlagChirp(chirpID, flags)
+	if err != nil {
+		log.Printf("Flagging chirp %d: %s", chirpID, err)
 	}
-	return strings.Join(chirpSlice, " ")
 }
 
 func (cfg *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -303,6 +547,14 @@ func (cfg *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		)
 		return
 	}
+	if impersonating(claims) {
+		httphandler.RespondWithError(
+			w,
+			http.StatusForbidden,
+			"Impersonation tokens can't change credentials",
+		)
+		return
+	}
 
 	expirationTime, err := jwtToken.Claims.GetExpirationTime()
 	if err != nil {
@@ -329,12 +581,45 @@ func (cfg *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resUser, err := cfg.Database.UpdateUser(userId, params.Email, params.Password)
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, "Token subject is not a user ID")
+		return
+	}
+	// Users who must reset their password can still do so here.
+	sessionID, _ := claims["sid"].(string)
+	err = cfg.Database.ValidateAccess(id, sessionID)
+	if err != nil && !errors.Is(err, database.ErrPasswordResetRequired) {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+	oldUser, err := cfg.Database.GetUser(id)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	resUser, err := cfg.Database.UpdateUser(userId, params.Email, params.Password, params.Handle)
+	if errors.Is(err, database.ErrInvalidHandle) || errors.Is(err, database.ErrHandleTaken) {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
 		return
 	}
 
+	// UpdateUser always replaces the password, so every update changes it.
+	cfg.recordAudit(r, audit.Entry{Event: audit.EventPasswordChanged, ActorID: id, SubjectID: id})
+	if resUser.Email != oldUser.Email {
+		cfg.recordAudit(r, audit.Entry{
+			Event:     audit.EventEmailChanged,
+			ActorID:   id,
+			SubjectID: id,
+			Details:   map[string]string{"from": oldUser.Email, "to": resUser.Email},
+		})
+	}
+
 	httphandler.RespondWithJSON(w, http.StatusOK, resUser)
 }
 
@@ -349,7 +634,11 @@ func (cfg *ApiConfig) UserLogin(w http.ResponseWriter, r *http.Request) {
 		Email        string `json:"email"`
 		Token        string `json:"token"`
 		ChirpyRed    bool   `json:"is_chirpy_red"`
+		Role         string `json:"role"`
 		RefreshToken string `json:"refresh_token"`
+		// PasswordResetRequired means every endpoint but PUT /api/users
+		// refuses the token until the password has been changed.
+		PasswordResetRequired bool `json:"password_reset_required,omitempty"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -366,48 +655,107 @@ func (cfg *ApiConfig) UserLogin(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.Database.ValidateLogin(params.Email, params.Password)
 	if err != nil {
+		cfg.recordAudit(r, audit.Entry{
+			Event:   audit.EventLoginFailed,
+			Details: map[string]string{"email": params.Email, "reason": "invalid credentials"},
+		})
 		httphandler.RespondWithError(w, 401, "Unauthorized")
 		return
 	}
+	if user.Suspended(time.Now()) {
+		cfg.recordAudit(r, audit.Entry{
+			Event:     audit.EventLoginFailed,
+			SubjectID: user.ID,
+			Details:   map[string]string{"email": params.Email, "reason": "suspended"},
+		})
+		httphandler.RespondWithError(w, http.StatusForbidden, suspensionMessage(user))
+		return
+	}
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	session, err := cfg.Database.CreateSession(database.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		IP:        r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+		ExpiresAt: time.Now().Add(refreshTokenLifetime).UTC(),
+	})
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
 
 	// Creating Access and Refresh token
-	signedJwtAccessToken, err := generateJwtToken(user.ID, "chirpy-access", cfg.JwtSecret)
+	claims := tokenClaims{Role: user.Role, SessionID: session.ID}
+	signedJwtAccessToken, err := generateJwtToken(user.ID, "chirpy-access", cfg.JwtSecret, claims)
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
 		return
 	}
 
-	signedJwtRefreshToken, err := generateJwtToken(user.ID, "chirpy-refresh", cfg.JwtSecret)
+	signedJwtRefreshToken, err := generateJwtToken(user.ID, "chirpy-refresh", cfg.JwtSecret, claims)
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
 		return
 	}
 	res := responseUser{
-		ID:           user.ID,
-		Email:        user.Email,
-		Token:        signedJwtAccessToken,
-		RefreshToken: signedJwtRefreshToken,
-		ChirpyRed:    user.ChirpyRed,
+		ID:                    user.ID,
+		Email:                 user.Email,
+		Token:                 signedJwtAccessToken,
+		RefreshToken:          signedJwtRefreshToken,
+		ChirpyRed:             user.ChirpyRed,
+		Role:                  user.Role,
+		PasswordResetRequired: user.PasswordResetRequired,
 	}
+	cfg.recordAudit(r, audit.Entry{
+		Event:     audit.EventLoginSucceeded,
+		ActorID:   user.ID,
+		SubjectID: user.ID,
+		Details:   map[string]string{"session_id": session.ID},
+	})
 
 	httphandler.RespondWithJSON(w, 200, res)
 }
 
-func generateJwtToken(id int, issuer, secret string) (string, error) {
+const (
+	accessTokenLifetime  = time.Hour
+	refreshTokenLifetime = 60 * 24 * time.Hour
+)
+
+// tokenClaims are the claims Chirpy adds to its tokens. Role is the user's
+// role when the token was issued, so it may be stale by the time the token
+// is used. SessionID ties the token to a stored session, which can be
+// revoked. ImpersonatorID marks tokens an admin uses to act as the user.
+// Only SessionID is kept in refresh tokens.
+type tokenClaims struct {
+	Role           string `json:"role,omitempty"`
+	SessionID      string `json:"sid,omitempty"`
+	ImpersonatorID int    `json:"imp,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// generateJwtToken signs a token for user id carrying claims.
+func generateJwtToken(id int, issuer, secret string, claims tokenClaims) (string, error) {
 	var claimIssuer string
 	var expirationTime *jwt.NumericDate
 	switch issuer {
 	case "chirpy-access":
 		claimIssuer = "chirpy-access"
-		expirationTime = jwt.NewNumericDate(time.Now().Add(1 * time.Hour).UTC())
+		expirationTime = jwt.NewNumericDate(time.Now().Add(accessTokenLifetime).UTC())
 	case "chirpy-refresh":
 		claimIssuer = "chirpy-refresh"
-		expirationTime = jwt.NewNumericDate(time.Now().Add(60 * 24 * time.Hour).UTC())
+		expirationTime = jwt.NewNumericDate(time.Now().Add(refreshTokenLifetime).UTC())
+		claims = tokenClaims{SessionID: claims.SessionID}
 	default:
 		return "", errors.New("Issuer string isn't valid")
 	}
 
-	jwtClaim := jwt.RegisteredClaims{
+	jwtClaim := claims
+	jwtClaim.RegisteredClaims = jwt.RegisteredClaims{
 		Issuer:    claimIssuer,
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: expirationTime,
@@ -423,6 +771,71 @@ func generateJwtToken(id int, issuer, secret string) (string, error) {
 	return signedJwtToken, nil
 }
 
+// authenticateAccessToken validates the bearer access token on the request
+// and returns the ID of the user it was issued to.
+func (cfg *ApiConfig) authenticateAccessToken(r *http.Request) (int, error) {
+	id, _, err := cfg.authenticateAccessTokenRole(r)
+	return id, err
+}
+
+// authenticateAccessTokenRole is authenticateAccessToken that also returns
+// the role claimed by the token. Tokens of revoked sessions and of users who
+// must reset their password are refused.
+func (cfg *ApiConfig) authenticateAccessTokenRole(r *http.Request) (int, string, error) {
+	tokenString := r.Header.Get("Authorization")
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	claims := jwt.MapClaims{}
+	jwtToken, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return []byte(cfg.JwtSecret), nil
+		},
+	)
+	if err != nil {
+		return 0, "", errors.New("Token is invalid")
+	}
+
+	tokenIssuer, err := jwtToken.Claims.GetIssuer()
+	if err != nil {
+		return 0, "", err
+	}
+
+	if tokenIssuer != "chirpy-access" {
+		return 0, "", errors.New("Token is not an access token")
+	}
+
+	tokenSubject, err := jwtToken.Claims.GetSubject()
+	if err != nil {
+		return 0, "", err
+	}
+
+	id, err := strconv.Atoi(tokenSubject)
+	if err != nil {
+		return 0, "", errors.New("Token subject is not a user ID")
+	}
+	sessionID, _ := claims["sid"].(string)
+	err = cfg.Database.ValidateAccess(id, sessionID)
+	if err != nil {
+		return 0, "", err
+	}
+	role, _ := claims["role"].(string)
+	return id, role, nil
+}
+
+// viewerID returns the authenticated caller of a read endpoint, or 0 for
+// anonymous callers and callers with an unusable token.
+func (cfg *ApiConfig) viewerID(r *http.Request) int {
+	if r.Header.Get("Authorization") == "" {
+		return 0
+	}
+	id, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
 func (cfg *ApiConfig) RefreshHandler(w http.ResponseWriter, r *http.Request) {
 	type returnToken struct {
 		Token string `json:"token"`
@@ -475,7 +888,33 @@ func (cfg *ApiConfig) RefreshHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	returnAccessToken, err := generateJwtToken(id, "chirpy-access", cfg.JwtSecret)
+	// Refresh tokens issued before sessions existed have no session ID, so
+	// they can't be revoked with the sessions and are no longer accepted.
+	sessionID, _ := claims["sid"].(string)
+	_, err = cfg.Database.TouchSession(sessionID, id)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, "Token is revoked")
+		return
+	}
+
+	// The user is read again so that refreshed tokens pick up role changes
+	// and suspended users can't stay logged in.
+	user, err := cfg.Database.GetUser(id)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+	if user.Suspended(time.Now()) {
+		httphandler.RespondWithError(w, http.StatusForbidden, suspensionMessage(user))
+		return
+	}
+
+	returnAccessToken, err := generateJwtToken(
+		id,
+		"chirpy-access",
+		cfg.JwtSecret,
+		tokenClaims{Role: user.Role, SessionID: sessionID},
+	)
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
 		return
@@ -484,6 +923,7 @@ func (cfg *ApiConfig) RefreshHandler(w http.ResponseWriter, r *http.Request) {
 	returnAccessTokenStruct := returnToken{
 		Token: returnAccessToken,
 	}
+	cfg.recordAudit(r, audit.Entry{Event: audit.EventTokenRefreshed, ActorID: id, SubjectID: id})
 	httphandler.RespondWithJSON(w, http.StatusOK, returnAccessTokenStruct)
 }
 
@@ -513,6 +953,14 @@ func (cfg *ApiConfig) RevokeHandler(w http.ResponseWriter, r *http.Request) {
 		httphandler.RespondWithError(w, http.StatusUnauthorized, "Token is not a refresh token")
 		return
 	}
+	if impersonating(claims) {
+		httphandler.RespondWithError(
+			w,
+			http.StatusForbidden,
+			"Impersonation tokens can't change credentials",
+		)
+		return
+	}
 
 	err = cfg.Database.CheckRefreshTokenRevoked(tokenString)
 	if err != nil {
@@ -525,6 +973,17 @@ func (cfg *ApiConfig) RevokeHandler(w http.ResponseWriter, r *http.Request) {
 		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
 		return
 	}
+	if sessionID, _ := claims["sid"].(string); sessionID != "" {
+		err = cfg.Database.RevokeSession(sessionID)
+		if err != nil && !errors.Is(err, database.ErrSessionRevoked) {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	// The token has been verified, so its subject is the user it was issued to.
+	idStr, _ := jwtToken.Claims.GetSubject()
+	id, _ := strconv.Atoi(idStr)
+	cfg.recordAudit(r, audit.Entry{Event: audit.EventTokenRevoked, ActorID: id, SubjectID: id})
 	httphandler.RespondWithJSON(w, http.StatusOK, returnToken)
 }
 
@@ -552,14 +1011,23 @@ func (cfg *ApiConfig) UserUpgradeHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if requestParams.Event != "user.upgraded" {
+	switch requestParams.Event {
+	case "user.upgraded":
+		err = cfg.Database.UpgradeUser(requestParams.Data.UserID)
+	case "user.downgraded":
+		err = cfg.Database.DowngradeUser(requestParams.Data.UserID, cfg.PinLimit)
+	default:
 		httphandler.RespondWithJSON(w, http.StatusOK, params{})
 		return
 	}
-	err = cfg.Database.UpgradeUser(requestParams.Data.UserID)
 	if err != nil {
 		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
 		return
 	}
+	event := audit.EventChirpyRedUpgraded
+	if requestParams.Event == "user.downgraded" {
+		event = audit.EventChirpyRedRemoved
+	}
+	cfg.recordAudit(r, audit.Entry{Event: event, SubjectID: requestParams.Data.UserID})
 	httphandler.RespondWithJSON(w, http.StatusOK, params{})
 }
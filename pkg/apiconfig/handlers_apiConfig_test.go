@@ -0,0 +1,67 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AxterDoesCode/webserver/internal/contentfilter"
+)
+
+func TestFilterBodyLength(t *testing.T) {
+	cfg := &ApiConfig{ContentFilter: contentfilter.DefaultPipeline()}
+
+	tests := []struct {
+		name       string
+		body       string
+		maxLength  int
+		wantLength int
+	}{
+		{"40 emoji fit in 140", strings.Repeat("😀", 40), 140, 0},
+		{"exactly at the limit", strings.Repeat("a", 140), 140, 0},
+		{"one over the limit", strings.Repeat("a", 141), 140, 141},
+		{"links count as a fixed length", "https://example.com/" + strings.Repeat("a", 300), 140, 0},
+		{"longer limit for Chirpy Red", strings.Repeat("a", 200), 280, 0},
+		{"emoji over the limit", strings.Repeat("🇳🇿", 141), 140, 141},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := cfg.filterBody(tt.body, tt.maxLength)
+			if tt.wantLength == 0 {
+				if err != nil {
+					t.Errorf("filterBody: %v", err)
+				}
+				return
+			}
+
+			var tooLong chirpTooLongError
+			if !errors.As(err, &tooLong) {
+				t.Fatalf("filterBody error = %v, want chirpTooLongError", err)
+			}
+			if tooLong.Length != tt.wantLength || tooLong.MaxLength != tt.maxLength {
+				t.Errorf("error reports %d of %d, want %d of %d",
+					tooLong.Length, tooLong.MaxLength, tt.wantLength, tt.maxLength)
+			}
+
+			rec := httptest.NewRecorder()
+			respondWithChirpBodyError(rec, err)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+			res := struct {
+				Length    int `json:"length"`
+				MaxLength int `json:"max_length"`
+			}{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if res.Length != tt.wantLength || res.MaxLength != tt.maxLength {
+				t.Errorf("response reports %d of %d, want %d of %d",
+					res.Length, res.MaxLength, tt.wantLength, tt.maxLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package apiconfig
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/audit"
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// recordAudit appends an event to the audit log, marking it when the
+// request was made with an impersonation token. The action it records has
+// already happened by then, so a failed write is only logged.
+func (cfg *ApiConfig) recordAudit(r *http.Request, entry audit.Entry) {
+	entry.IP = r.RemoteAddr
+	if entry.ImpersonatorID == 0 {
+		entry.ImpersonatorID = cfg.impersonatorID(r)
+	}
+	_, err := cfg.Audit.Append(entry)
+	if err != nil {
+		log.Printf("Recording %s in the audit log: %s", entry.Event, err)
+	}
+}
+
+// MiddlewareAudit records every request that changes something through the
+// routes it wraps as an admin action, along with the response status.
+func (cfg *ApiConfig) MiddlewareAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// URL parameters are only known once the request has been routed.
+		entry := audit.Entry{
+			Event:   audit.EventAdminAction,
+			ActorID: cfg.viewerID(r),
+			Details: map[string]string{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"status": strconv.Itoa(recorder.status),
+			},
+		}
+		if userID, err := strconv.Atoi(chi.URLParam(r, "userID")); err == nil {
+			entry.SubjectID = userID
+		}
+		cfg.recordAudit(r, entry)
+	})
+}
+
+// MiddlewareAuditImpersonation records every change made through the routes
+// it wraps with an impersonation token.
+func (cfg *ApiConfig) MiddlewareAuditImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || cfg.impersonatorID(r) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		userID := cfg.viewerID(r)
+		cfg.recordAudit(r, audit.Entry{
+			Event:     audit.EventImpersonatedRequest,
+			ActorID:   userID,
+			SubjectID: userID,
+			Details: map[string]string{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"status": strconv.Itoa(recorder.status),
+			},
+		})
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// GetAuditHandler lists the audit log, newest first. It can be filtered by
+// event, actor_id, subject_id, since and until.
+func (cfg *ApiConfig) GetAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.confirmRole(w, r, database.RoleAdmin); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{Event: query.Get("event")}
+
+	var err error
+	filter.ActorID, err = parseIDParam(r, "actor_id")
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	filter.SubjectID, err = parseIDParam(r, "subject_id")
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	filter.Since, err = parseTimeParam(r, "since")
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	filter.Until, err = parseTimeParam(r, "until")
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	filter.Limit, err = parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	scope := fmt.Sprintf(
+		"audit:%s:%d:%d:%s:%s",
+		filter.Event,
+		filter.ActorID,
+		filter.SubjectID,
+		query.Get("since"),
+		query.Get("until"),
+	)
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	if after != nil {
+		filter.BeforeSeq = after.ID
+	}
+
+	entries, hasMore, err := cfg.Audit.Query(filter)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type auditPage struct {
+		Entries    []audit.Entry `json:"entries"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+	}
+	res := auditPage{Entries: entries}
+	if hasMore {
+		last := entries[len(entries)-1]
+		res.NextCursor, err = cfg.nextCursor(scope, &database.Position{CreatedAt: last.At, ID: last.Seq})
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+// parseIDParam reads an optional ID query parameter, returning 0 when it
+// isn't set.
+func parseIDParam(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return id, nil
+}
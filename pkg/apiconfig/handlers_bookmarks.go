@@ -0,0 +1,95 @@
+package apiconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) BookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeBookmark(w, r, cfg.Database.BookmarkChirp)
+}
+
+func (cfg *ApiConfig) UnbookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeBookmark(w, r, cfg.Database.UnbookmarkChirp)
+}
+
+func (cfg *ApiConfig) GetBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	scope := fmt.Sprintf("bookmarks:%d", userID)
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	chirps, last, err := cfg.Database.GetBookmarks(userID, after, limit)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type bookmarkPage struct {
+		Chirps     []database.Chirp `json:"chirps"`
+		NextCursor string           `json:"next_cursor,omitempty"`
+	}
+	res := bookmarkPage{Chirps: chirps}
+	res.NextCursor, err = cfg.nextCursor(scope, last)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) changeBookmark(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(chirpID, userID int) error,
+) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	err = change(chirpID, userID)
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case errors.Is(err, database.ErrAlreadyBookmarked):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrNotBookmarked):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,128 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) EditChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	author, err := cfg.Database.GetUser(userID)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+	if author.Suspended(time.Now()) {
+		httphandler.RespondWithError(w, http.StatusForbidden, suspensionMessage(author))
+		return
+	}
+
+	body, flags, err := cfg.prepareChirpBody(params.Body, author)
+	if err != nil {
+		respondWithChirpBodyError(w, err)
+		return
+	}
+
+	// Drafts and scheduled chirps can be changed freely until they are
+	// published.
+	if pending, err := cfg.Database.GetPendingChirp(chirpID, userID); err == nil {
+		edited, err := cfg.Database.UpdatePendingChirp(chirpID, userID, body, pending.PublishAt)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+		cfg.recordContentFlags(edited.ID, flags)
+		httphandler.RespondWithJSON(w, http.StatusOK, edited)
+		return
+	}
+
+	chirp, err := cfg.Database.GetChirpByID(chirpID, userID)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	}
+
+	if cfg.ChirpEditRequiresRed && !author.ChirpyRed {
+		httphandler.RespondWithError(
+			w,
+			http.StatusForbidden,
+			"Editing chirps requires Chirpy Red",
+		)
+		return
+	}
+
+	if cfg.ChirpEditWindow > 0 && time.Since(chirp.CreatedAt) > cfg.ChirpEditWindow {
+		httphandler.RespondWithError(
+			w,
+			http.StatusForbidden,
+			fmt.Sprintf("Chirps can only be edited within %s of posting", cfg.ChirpEditWindow),
+		)
+		return
+	}
+
+	edited, err := cfg.Database.EditChirp(chirpID, userID, body)
+	switch {
+	case errors.Is(err, database.ErrNotChirpAuthor):
+		httphandler.RespondWithError(w, http.StatusForbidden, "Cannot Edit: Chirp does not belong to user")
+		return
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	cfg.recordContentFlags(edited.ID, flags)
+	httphandler.RespondWithJSON(w, http.StatusOK, edited)
+}
+
+func (cfg *ApiConfig) GetChirpHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	revisions, err := cfg.Database.GetChirpHistory(chirpID, cfg.viewerID(r))
+	if errors.Is(err, database.ErrChirpNotFound) {
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, revisions)
+}
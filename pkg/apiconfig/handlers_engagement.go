@@ -0,0 +1,111 @@
+package apiconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) LikeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeEngagement(w, r, cfg.Database.LikeChirp)
+}
+
+func (cfg *ApiConfig) UnlikeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeEngagement(w, r, cfg.Database.UnlikeChirp)
+}
+
+func (cfg *ApiConfig) RechirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeEngagement(w, r, cfg.Database.Rechirp)
+}
+
+func (cfg *ApiConfig) UnrechirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeEngagement(w, r, cfg.Database.Unrechirp)
+}
+
+func (cfg *ApiConfig) GetLikersHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	scope := fmt.Sprintf("likers:%d", chirpID)
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	users, last, err := cfg.Database.GetLikers(chirpID, cfg.viewerID(r), after, limit)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type likersPage struct {
+		Users      []database.User `json:"users"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+	}
+	res := likersPage{Users: users}
+	res.NextCursor, err = cfg.nextCursor(scope, last)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) changeEngagement(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(chirpID, userID int) (database.Chirp, error),
+) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	chirp, err := change(chirpID, userID)
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case errors.Is(err, database.ErrAlreadyEngaged):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrNotEngaged):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrNotRechirpable):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, chirp)
+}
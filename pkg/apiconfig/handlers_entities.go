@@ -0,0 +1,51 @@
+package apiconfig
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) GetTagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	// chi matches on the raw path when the client's escaping differs from
+	// Go's, so non-ASCII tags can still be percent-encoded here.
+	rawTag, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Tag is not a valid path segment")
+		return
+	}
+	tag := database.NormalizeTag(rawTag)
+	if tag == "" {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Tag is empty")
+		return
+	}
+
+	query := database.ChirpQuery{
+		ViewerID:  cfg.viewerID(r),
+		Tag:       tag,
+		SortField: "created_at",
+		SortDesc:  true,
+	}
+	cfg.respondWithChirpPage(w, r, query, "tag:"+tag)
+}
+
+func (cfg *ApiConfig) GetMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	query := database.ChirpQuery{
+		ViewerID:        userID,
+		MentionedUserID: userID,
+		SortField:       "created_at",
+		SortDesc:        true,
+	}
+	cfg.respondWithChirpPage(w, r, query, fmt.Sprintf("mentions:%d", userID))
+}
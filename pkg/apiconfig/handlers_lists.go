@@ -0,0 +1,194 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) CreateListHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Private     bool   `json:"private"`
+	}
+
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	list, err := cfg.Database.CreateList(userID, params.Name, params.Description, params.Private)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusCreated, list)
+}
+
+// GetListsHandler returns the lists the caller owns or subscribes to.
+func (cfg *ApiConfig) GetListsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	lists, err := cfg.Database.GetLists(userID)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, lists)
+}
+
+func (cfg *ApiConfig) GetListHandler(w http.ResponseWriter, r *http.Request) {
+	listID, ok := listIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := cfg.Database.GetList(listID, cfg.viewerID(r))
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, list)
+}
+
+func (cfg *ApiConfig) UpdateListHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Private     *bool   `json:"private"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	cfg.changeList(w, r, func(listID, userID int) (database.List, error) {
+		return cfg.Database.UpdateList(listID, userID, database.ListChanges{
+			Name:        params.Name,
+			Description: params.Description,
+			Private:     params.Private,
+		})
+	})
+}
+
+func (cfg *ApiConfig) DeleteListHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+	listID, ok := listIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	err = cfg.Database.DeleteList(listID, userID)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) AddListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeListMember(w, r, cfg.Database.AddListMember)
+}
+
+func (cfg *ApiConfig) RemoveListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeListMember(w, r, cfg.Database.RemoveListMember)
+}
+
+func (cfg *ApiConfig) SubscribeToListHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeList(w, r, cfg.Database.SubscribeToList)
+}
+
+func (cfg *ApiConfig) UnsubscribeFromListHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeList(w, r, cfg.Database.UnsubscribeFromList)
+}
+
+func (cfg *ApiConfig) changeListMember(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(listID, ownerID, memberID int) (database.List, error),
+) {
+	memberID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "User ID must be an integer")
+		return
+	}
+	cfg.changeList(w, r, func(listID, userID int) (database.List, error) {
+		return change(listID, userID, memberID)
+	})
+}
+
+func (cfg *ApiConfig) changeList(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(listID, userID int) (database.List, error),
+) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+	listID, ok := listIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := change(listID, userID)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, list)
+}
+
+func listIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	listID, err := strconv.Atoi(chi.URLParam(r, "listID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "List ID must be an integer")
+		return 0, false
+	}
+	return listID, true
+}
+
+func respondWithListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrListNotFound), errors.Is(err, database.ErrUserNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+	case errors.Is(err, database.ErrNotListOwner):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+	case errors.Is(err, database.ErrInvalidListName),
+		errors.Is(err, database.ErrTooManyListMembers),
+		errors.Is(err, database.ErrOwnListSubscription):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+	default:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+	}
+}
@@ -0,0 +1,214 @@
+package apiconfig
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/blobstore"
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/internal/media"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// UploadMediaHandler accepts an image in the "file" field of a multipart
+// form and stores it, with a thumbnail, for attaching to chirps.
+func (cfg *ApiConfig) UploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	// Leave room for the rest of the form, but no more.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MediaMaxBytes+1<<20)
+	data, err := cfg.readUpload(r)
+	if errors.Is(err, errUploadTooLarge) {
+		httphandler.RespondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("%s", err))
+		return
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	processed, err := media.Process(data)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		httphandler.RespondWithError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, media.ErrTooLarge), errors.Is(err, media.ErrInvalidImage):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	id, err := newMediaID()
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	record := database.Media{
+		ID:            id,
+		OwnerID:       userID,
+		ContentType:   processed.ContentType,
+		Key:           id + extension(processed.ContentType),
+		Size:          len(processed.Data),
+		Width:         processed.Width,
+		Height:        processed.Height,
+		ThumbnailType: processed.ThumbnailType,
+		ThumbnailKey:  id + "_thumb" + extension(processed.ThumbnailType),
+	}
+
+	err = cfg.BlobStore.Put(r.Context(), record.Key, processed.Data, record.ContentType)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, "Couldn't store media")
+		log.Print(err)
+		return
+	}
+	err = cfg.BlobStore.Put(r.Context(), record.ThumbnailKey, processed.Thumbnail, record.ThumbnailType)
+	if err != nil {
+		cfg.BlobStore.Delete(r.Context(), record.Key)
+		httphandler.RespondWithError(w, http.StatusInternalServerError, "Couldn't store media")
+		log.Print(err)
+		return
+	}
+
+	record, err = cfg.Database.AddMedia(record)
+	if err != nil {
+		cfg.BlobStore.Delete(r.Context(), record.Key)
+		cfg.BlobStore.Delete(r.Context(), record.ThumbnailKey)
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type uploadResponse struct {
+		database.MediaAttachment
+		Size int `json:"size"`
+	}
+	httphandler.RespondWithJSON(w, http.StatusCreated, uploadResponse{
+		MediaAttachment: record.Attachment(),
+		Size:            record.Size,
+	})
+}
+
+func (cfg *ApiConfig) GetMediaHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, false)
+}
+
+func (cfg *ApiConfig) GetMediaThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, true)
+}
+
+// serveMedia serves an upload or its thumbnail. Uploads never change once
+// stored, so those on public chirps can be cached indefinitely. The rest
+// aren't cached at all, so that they don't outlive the access that let
+// them be fetched or the chirp being hidden.
+func (cfg *ApiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	record, err := cfg.Database.GetMedia(chi.URLParam(r, "mediaID"))
+	if errors.Is(err, database.ErrMediaNotFound) {
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	key, contentType, etag := record.Key, record.ContentType, `"`+record.ID+`"`
+	if thumbnail {
+		key, contentType, etag = record.ThumbnailKey, record.ThumbnailType, `"`+record.ID+`-thumb"`
+	}
+
+	if record.Public {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	blob, err := cfg.BlobStore.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		w.Header().Del("Cache-Control")
+		httphandler.RespondWithError(w, http.StatusNotFound, "Media doesn't exist")
+		return
+	}
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+var errUploadTooLarge = errors.New("Upload is too large")
+
+// readUpload reads the "file" part of a multipart request, refusing files
+// over cfg.MediaMaxBytes without reading the rest of them.
+func (cfg *ApiConfig) readUpload(r *http.Request) ([]byte, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("Request must be multipart/form-data")
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("Request has no file field")
+		}
+		if err != nil {
+			return nil, errors.New("Couldn't read the multipart form")
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, io.LimitReader(part, cfg.MediaMaxBytes+1))
+		if err != nil {
+			return nil, errors.New("Couldn't read the file")
+		}
+		if int64(buf.Len()) > cfg.MediaMaxBytes {
+			return nil, errUploadTooLarge
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+func newMediaID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	}
+	return ""
+}
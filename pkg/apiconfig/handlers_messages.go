@@ -0,0 +1,248 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// maxMessageLength is the longest direct message, measured like chirps.
+const maxMessageLength = 1000
+
+func (cfg *ApiConfig) CreateConversationHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MemberIDs []int `json:"member_ids"`
+	}
+
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	conversation, created, err := cfg.Database.CreateConversation(userID, params.MemberIDs)
+	if err != nil {
+		respondWithConversationError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	httphandler.RespondWithJSON(w, status, conversation)
+}
+
+func (cfg *ApiConfig) GetConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	scope := fmt.Sprintf("conversations:%d", userID)
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	conversations, hasMore, err := cfg.Database.GetConversations(userID, after, limit)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type conversationPage struct {
+		Conversations []database.Conversation `json:"conversations"`
+		NextCursor    string                  `json:"next_cursor,omitempty"`
+	}
+	res := conversationPage{Conversations: conversations}
+	if hasMore {
+		last := conversations[len(conversations)-1].Position()
+		res.NextCursor, err = cfg.nextCursor(scope, &last)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) GetConversationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	conversation, err := cfg.Database.GetConversation(conversationID, userID)
+	if err != nil {
+		respondWithConversationError(w, err)
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, conversation)
+}
+
+func (cfg *ApiConfig) GetMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	scope := fmt.Sprintf("messages:%d", conversationID)
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	messages, hasMore, err := cfg.Database.GetMessages(conversationID, userID, after, limit)
+	if err != nil {
+		respondWithConversationError(w, err)
+		return
+	}
+
+	type messagePage struct {
+		Messages   []database.Message `json:"messages"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}
+	res := messagePage{Messages: messages}
+	if hasMore {
+		last := messages[len(messages)-1].Position()
+		res.NextCursor, err = cfg.nextCursor(scope, &last)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+// SendMessageHandler sends a message to a conversation. Messages go through
+// the same content filter as chirps, but since they are private, only the
+// rules that mask or reject apply and flags are dropped.
+func (cfg *ApiConfig) SendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, conversationID, ok := cfg.conversationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Message body is required")
+		return
+	}
+
+	body, _, err := cfg.filterBody(params.Body, maxMessageLength)
+	if err != nil {
+		respondWithChirpBodyError(w, err)
+		return
+	}
+
+	message, err := cfg.Database.SendMessage(conversationID, userID, body)
+	if err != nil {
+		respondWithConversationError(w, err)
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusCreated, message)
+}
+
+// MarkConversationReadHandler moves the caller's read receipt to the given
+// message, or to the latest one when none is given.
+func (cfg *ApiConfig) MarkConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MessageID int `json:"message_id"`
+	}
+
+	userID, conversationID, ok := cfg.conversationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&params)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+			return
+		}
+	}
+
+	conversation, err := cfg.Database.MarkConversationRead(conversationID, userID, params.MessageID)
+	if err != nil {
+		respondWithConversationError(w, err)
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, conversation)
+}
+
+// conversationRequest authenticates the caller and reads the conversation ID
+// from the path, responding with an error if either fails.
+func (cfg *ApiConfig) conversationRequest(w http.ResponseWriter, r *http.Request) (userID, conversationID int, ok bool) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return 0, 0, false
+	}
+
+	conversationID, err = strconv.Atoi(chi.URLParam(r, "conversationID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Conversation ID must be an integer")
+		return 0, 0, false
+	}
+	return userID, conversationID, true
+}
+
+func respondWithConversationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrConversationNotFound),
+		errors.Is(err, database.ErrMessageNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+	case errors.Is(err, database.ErrUserNotFound),
+		errors.Is(err, database.ErrNoRecipients),
+		errors.Is(err, database.ErrTooManyMembers):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+	case errors.Is(err, database.ErrMessageBlocked):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+	default:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+	}
+}
@@ -0,0 +1,248 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+const (
+	defaultSuspensionDays = 7
+	maxSuspensionDays     = 365
+)
+
+// GetReportsHandler lists the moderation queue, oldest report first. The
+// status parameter picks open, claimed or resolved reports; without it the
+// reports still waiting for a decision are listed.
+func (cfg *ApiConfig) GetReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.confirmRole(w, r, database.RoleModerator); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", database.ReportOpen, database.ReportClaimed, database.ReportResolved:
+	default:
+		httphandler.RespondWithError(w, http.StatusBadRequest, "status must be open, claimed or resolved")
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	scope := "reports:" + status
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	reports, hasMore, err := cfg.Database.GetReports(status, after, limit)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type reportPage struct {
+		Reports    []database.Report `json:"reports"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+	}
+	res := reportPage{Reports: reports}
+	if hasMore {
+		last := reports[len(reports)-1].Position()
+		res.NextCursor, err = cfg.nextCursor(scope, &last)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) GetReportHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeReport(w, r, func(reportID, moderatorID int) (database.Report, error) {
+		return cfg.Database.GetReport(reportID)
+	})
+}
+
+func (cfg *ApiConfig) ClaimReportHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeReport(w, r, cfg.Database.ClaimReport)
+}
+
+func (cfg *ApiConfig) ResolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action      string `json:"action"`
+		Note        string `json:"note"`
+		SuspendDays int    `json:"suspend_days"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if params.SuspendDays == 0 {
+		params.SuspendDays = defaultSuspensionDays
+	}
+	if params.SuspendDays < 1 || params.SuspendDays > maxSuspensionDays {
+		httphandler.RespondWithError(
+			w,
+			http.StatusBadRequest,
+			fmt.Sprintf("suspend_days must be between 1 and %d", maxSuspensionDays),
+		)
+		return
+	}
+
+	cfg.changeReport(w, r, func(reportID, moderatorID int) (database.Report, error) {
+		report, err := cfg.Database.ResolveReport(reportID, moderatorID, database.Resolution{
+			Action:     params.Action,
+			Note:       params.Note,
+			SuspendFor: time.Duration(params.SuspendDays) * 24 * time.Hour,
+		})
+		if err == nil && params.Action == database.ResolutionSuspend {
+			cfg.Scheduler.Wake()
+		}
+		return report, err
+	})
+}
+
+// UnhideChirpHandler restores a chirp a moderator hid, for instance after
+// an appeal.
+func (cfg *ApiConfig) UnhideChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Note string `json:"note"`
+	}
+
+	moderatorID, ok := cfg.confirmRole(w, r, database.RoleModerator)
+	if !ok {
+		return
+	}
+
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	params := parameters{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err = decoder.Decode(&params)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+			return
+		}
+	}
+
+	chirp, err := cfg.Database.UnhideChirp(chirpID, moderatorID, params.Note)
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case errors.Is(err, database.ErrChirpNotHidden):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrModerateSelf):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, chirp)
+}
+
+// GetModerationLogHandler returns the moderation audit trail, newest first.
+func (cfg *ApiConfig) GetModerationLogHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.confirmRole(w, r, database.RoleModerator); !ok {
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	const scope = "moderation-log"
+	after, err := cfg.cursorPosition(r, scope)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	actions, hasMore, err := cfg.Database.GetModerationLog(after, limit)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type logPage struct {
+		Actions    []database.ModerationAction `json:"actions"`
+		NextCursor string                      `json:"next_cursor,omitempty"`
+	}
+	res := logPage{Actions: actions}
+	if hasMore {
+		last := actions[len(actions)-1]
+		res.NextCursor, err = cfg.nextCursor(scope, &database.Position{CreatedAt: last.CreatedAt, ID: last.ID})
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) changeReport(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(reportID, moderatorID int) (database.Report, error),
+) {
+	moderatorID, ok := cfg.confirmRole(w, r, database.RoleModerator)
+	if !ok {
+		return
+	}
+
+	reportID, err := strconv.Atoi(chi.URLParam(r, "reportID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Report ID must be an integer")
+		return
+	}
+
+	report, err := change(reportID, moderatorID)
+	switch {
+	case errors.Is(err, database.ErrReportNotFound),
+		errors.Is(err, database.ErrChirpNotFound),
+		errors.Is(err, database.ErrUserNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrReportClaimed), errors.Is(err, database.ErrReportResolved):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrModerateSelf):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrInvalidResolution),
+		errors.Is(err, database.ErrNotChirpReport),
+		errors.Is(err, database.ErrSuspendAdmin):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,61 @@
+package apiconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) PinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changePin(w, r, func(chirpID, userID int) error {
+		return cfg.Database.PinChirp(chirpID, userID, cfg.PinLimit, cfg.PinLimitRed)
+	})
+}
+
+func (cfg *ApiConfig) UnpinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changePin(w, r, cfg.Database.UnpinChirp)
+}
+
+func (cfg *ApiConfig) changePin(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(chirpID, userID int) error,
+) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	err = change(chirpID, userID)
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case errors.Is(err, database.ErrNotChirpAuthor):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrAlreadyPinned), errors.Is(err, database.ErrTooManyPins):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrNotPinned):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
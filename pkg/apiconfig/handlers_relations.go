@@ -0,0 +1,107 @@
+package apiconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) BlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, cfg.Database.BlockUser)
+}
+
+func (cfg *ApiConfig) UnblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, cfg.Database.UnblockUser)
+}
+
+func (cfg *ApiConfig) MuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, cfg.Database.MuteUser)
+}
+
+func (cfg *ApiConfig) UnmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, cfg.Database.UnmuteUser)
+}
+
+func (cfg *ApiConfig) FollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, cfg.Database.FollowUser)
+}
+
+func (cfg *ApiConfig) UnfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, cfg.Database.UnfollowUser)
+}
+
+func (cfg *ApiConfig) GetFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listRelation(w, r, cfg.Database.GetFollowedUsers)
+}
+
+func (cfg *ApiConfig) GetFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listRelation(w, r, cfg.Database.GetFollowers)
+}
+
+func (cfg *ApiConfig) GetBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listRelation(w, r, cfg.Database.GetBlockedUsers)
+}
+
+func (cfg *ApiConfig) GetMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listRelation(w, r, cfg.Database.GetMutedUsers)
+}
+
+func (cfg *ApiConfig) changeRelation(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(fromID, toID int) error,
+) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	targetID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "User ID must be an integer")
+		return
+	}
+
+	err = change(userID, targetID)
+	switch {
+	case errors.Is(err, database.ErrSelfRelation):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrUserNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrFollowBlocked):
+		httphandler.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) listRelation(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(userID int) ([]database.User, error),
+) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	users, err := list(userID)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, users)
+}
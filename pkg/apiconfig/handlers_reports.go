@@ -0,0 +1,116 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) ReportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+	cfg.fileReport(w, r, func(reporterID int, reason, comment string) (database.Report, error) {
+		return cfg.Database.ReportChirp(reporterID, chirpID, reason, comment)
+	})
+}
+
+func (cfg *ApiConfig) ReportUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "User ID must be an integer")
+		return
+	}
+	cfg.fileReport(w, r, func(reporterID int, reason, comment string) (database.Report, error) {
+		return cfg.Database.ReportUser(reporterID, userID, reason, comment)
+	})
+}
+
+// GetWarningsHandler returns the warnings moderators gave the caller.
+func (cfg *ApiConfig) GetWarningsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	warnings, err := cfg.Database.GetWarnings(userID)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, warnings)
+}
+
+func (cfg *ApiConfig) fileReport(
+	w http.ResponseWriter,
+	r *http.Request,
+	file func(reporterID int, reason, comment string) (database.Report, error),
+) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Comment string `json:"comment"`
+	}
+
+	reporterID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	report, err := file(reporterID, params.Reason, params.Comment)
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case errors.Is(err, database.ErrUserNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrInvalidReportReason),
+		errors.Is(err, database.ErrReportCommentLength),
+		errors.Is(err, database.ErrSelfReport):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrAlreadyReported):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	// Reporters only get to see what they filed, not the moderation fields.
+	type receipt struct {
+		ID         int    `json:"id"`
+		TargetType string `json:"target_type"`
+		ChirpID    int    `json:"chirp_id,omitempty"`
+		UserID     int    `json:"user_id"`
+		Reason     string `json:"reason"`
+		Status     string `json:"status"`
+	}
+	httphandler.RespondWithJSON(w, http.StatusCreated, receipt{
+		ID:         report.ID,
+		TargetType: report.TargetType,
+		ChirpID:    report.ChirpID,
+		UserID:     report.UserID,
+		Reason:     report.Reason,
+		Status:     report.Status,
+	})
+}
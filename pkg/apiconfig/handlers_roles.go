@@ -0,0 +1,102 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// MiddlewareRequireRole only lets through requests whose access token claims
+// role or a role above it. The claim is only as fresh as the token, so
+// handlers for sensitive actions check the stored role again with
+// confirmRole.
+func (cfg *ApiConfig) MiddlewareRequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claimedRole, err := cfg.authenticateAccessTokenRole(r)
+			if err != nil {
+				httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+				return
+			}
+			if !database.RoleAtLeast(claimedRole, role) {
+				httphandler.RespondWithError(w, http.StatusForbidden, "Requires the "+role+" role")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// confirmRole authenticates the caller and checks their current role in the
+// store, so that a demoted user can't keep acting on an older token. It
+// responds with an error if they don't have role.
+func (cfg *ApiConfig) confirmRole(w http.ResponseWriter, r *http.Request, role string) (int, bool) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return 0, false
+	}
+
+	user, err := cfg.Database.GetUser(userID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return 0, false
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return 0, false
+	}
+	if !user.HasRole(role) {
+		httphandler.RespondWithError(w, http.StatusForbidden, "Requires the "+role+" role")
+		return 0, false
+	}
+	return userID, true
+}
+
+func (cfg *ApiConfig) SetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	if _, ok := cfg.confirmRole(w, r, database.RoleAdmin); !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "User ID must be an integer")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := cfg.Database.SetUserRole(userID, params.Role)
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrInvalidRole):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrLastAdmin):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,134 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) GetDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listPendingChirps(w, r, database.ChirpDraft)
+}
+
+func (cfg *ApiConfig) GetScheduledChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listPendingChirps(w, r, database.ChirpScheduled)
+}
+
+// ScheduleChirpHandler schedules a draft, or moves a scheduled chirp to a
+// new time.
+func (cfg *ApiConfig) ScheduleChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if params.PublishAt == nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "publish_at is required")
+		return
+	}
+	if err := checkPublishAt(*params.PublishAt); err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	cfg.changePendingChirp(w, r, func(chirpID, userID int, pending database.Chirp) (database.Chirp, error) {
+		return cfg.Database.UpdatePendingChirp(chirpID, userID, pending.Body, params.PublishAt)
+	})
+}
+
+// UnscheduleChirpHandler cancels a scheduled chirp, keeping it as a draft.
+func (cfg *ApiConfig) UnscheduleChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changePendingChirp(w, r, func(chirpID, userID int, pending database.Chirp) (database.Chirp, error) {
+		return cfg.Database.UpdatePendingChirp(chirpID, userID, pending.Body, nil)
+	})
+}
+
+// PublishChirpHandler publishes a draft or scheduled chirp straight away.
+func (cfg *ApiConfig) PublishChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changePendingChirp(w, r, func(chirpID, userID int, pending database.Chirp) (database.Chirp, error) {
+		return cfg.Database.PublishChirp(chirpID, userID)
+	})
+}
+
+func (cfg *ApiConfig) listPendingChirps(w http.ResponseWriter, r *http.Request, status string) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	chirps, err := cfg.Database.GetPendingChirps(userID, status)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, chirps)
+}
+
+// changePendingChirp applies change to one of the caller's drafts or
+// scheduled chirps and wakes the scheduler, since the next due time may
+// have moved.
+func (cfg *ApiConfig) changePendingChirp(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(chirpID, userID int, pending database.Chirp) (database.Chirp, error),
+) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	pending, err := cfg.Database.GetPendingChirp(chirpID, userID)
+	if err == nil {
+		pending, err = change(chirpID, userID, pending)
+	}
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	case errors.Is(err, database.ErrChirpNotPending):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+		return
+	case errors.Is(err, database.ErrUserSuspended):
+		message := fmt.Sprintf("%s", err)
+		if user, err := cfg.Database.GetUser(userID); err == nil {
+			message = suspensionMessage(user)
+		}
+		httphandler.RespondWithError(w, http.StatusForbidden, message)
+		return
+	case err != nil:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	cfg.Scheduler.Wake()
+	httphandler.RespondWithJSON(w, http.StatusOK, pending)
+}
+
+// checkPublishAt validates the time a chirp is scheduled for.
+func checkPublishAt(publishAt time.Time) error {
+	if !publishAt.After(time.Now()) {
+		return errors.New("publish_at must be in the future")
+	}
+	return nil
+}
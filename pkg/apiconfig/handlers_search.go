@@ -0,0 +1,80 @@
+package apiconfig
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+func (cfg *ApiConfig) SearchChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	text := r.URL.Query().Get("q")
+	if text == "" {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	order := r.URL.Query().Get("sort")
+	if order == "" {
+		order = "relevance"
+	}
+	if order != "relevance" && order != "newest" {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "sort must be relevance or newest")
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	query := database.SearchQuery{
+		Text:     text,
+		ViewerID: cfg.viewerID(r),
+		Newest:   order == "newest",
+		Limit:    limit,
+	}
+
+	// Relevance scores change as chirps come and go, so ranked pages are
+	// addressed by offset, carried in the cursor's ID.
+	scope := "search:" + order + ":" + text
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		cursor, err := cfg.decodeCursor(value, scope)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+			return
+		}
+		if query.Newest {
+			query.After = &database.Position{CreatedAt: cursor.CreatedAt, ID: cursor.ID}
+		} else {
+			query.Offset = cursor.ID
+		}
+	}
+
+	chirps, hasMore, err := cfg.Database.SearchChirps(query)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type searchPage struct {
+		Chirps     []database.Chirp `json:"chirps"`
+		NextCursor string           `json:"next_cursor,omitempty"`
+	}
+	res := searchPage{Chirps: chirps}
+	if hasMore {
+		next := pageCursor{Scope: scope, ID: query.Offset + len(chirps)}
+		if query.Newest {
+			last := chirps[len(chirps)-1]
+			next = pageCursor{Scope: scope, CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+		res.NextCursor, err = cfg.encodeCursor(next)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			return
+		}
+	}
+	setNextLink(w, r, res.NextCursor)
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,122 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// maxContentWarningLength is the longest content warning, measured like
+// chirps.
+const maxContentWarningLength = 100
+
+func (cfg *ApiConfig) GetPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	preferences, err := cfg.Database.GetPreferences(userID)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, preferences)
+}
+
+func (cfg *ApiConfig) UpdatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := database.Preferences{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	preferences, err := cfg.Database.UpdatePreferences(userID, params)
+	if errors.Is(err, database.ErrInvalidPreference) {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, preferences)
+}
+
+// SetChirpSensitivityHandler lets moderators add, change or remove a
+// chirp's content warning and sensitive flag.
+func (cfg *ApiConfig) SetChirpSensitivityHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ContentWarning *string `json:"content_warning"`
+		Sensitive      *bool   `json:"sensitive"`
+	}
+
+	moderatorID, ok := cfg.confirmRole(w, r, database.RoleModerator)
+	if !ok {
+		return
+	}
+
+	chirpID, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Chirp ID must be an integer")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if params.ContentWarning != nil {
+		contentWarning, err := cfg.prepareContentWarning(*params.ContentWarning)
+		if err != nil {
+			respondWithChirpBodyError(w, err)
+			return
+		}
+		params.ContentWarning = &contentWarning
+	}
+
+	chirp, err := cfg.Database.SetChirpSensitivity(chirpID, moderatorID, database.SensitivityChanges{
+		ContentWarning: params.ContentWarning,
+		Sensitive:      params.Sensitive,
+	})
+	if errors.Is(err, database.ErrChirpNotFound) {
+		httphandler.RespondWithError(w, http.StatusNotFound, "Chirp Doesn't exist")
+		return
+	}
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, chirp)
+}
+
+// prepareContentWarning applies the length limit and content filter to a
+// content warning. Blank warnings come back empty.
+func (cfg *ApiConfig) prepareContentWarning(contentWarning string) (string, error) {
+	contentWarning = strings.TrimSpace(contentWarning)
+	if contentWarning == "" {
+		return "", nil
+	}
+	contentWarning, _, err := cfg.filterBody(contentWarning, maxContentWarningLength)
+	return contentWarning, err
+}
@@ -0,0 +1,135 @@
+package apiconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// SuspendUserHandler suspends an account for a number of days, or bans it
+// when permanent is set.
+func (cfg *ApiConfig) SuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Days      int    `json:"days"`
+		Permanent bool   `json:"permanent"`
+		Reason    string `json:"reason"`
+		// HideChirps is left as it is on a suspension already in force
+		// when it isn't given.
+		HideChirps *bool `json:"hide_chirps"`
+	}
+
+	adminID, ok := cfg.confirmRole(w, r, database.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "User ID must be an integer")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	suspension := database.Suspension{Reason: params.Reason, HideChirps: params.HideChirps}
+	switch {
+	case params.Permanent && params.Days != 0:
+		httphandler.RespondWithError(w, http.StatusBadRequest, "A permanent ban can't have days")
+		return
+	case !params.Permanent:
+		if params.Days < 1 || params.Days > maxSuspensionDays {
+			httphandler.RespondWithError(
+				w,
+				http.StatusBadRequest,
+				fmt.Sprintf("days must be between 1 and %d", maxSuspensionDays),
+			)
+			return
+		}
+		suspension.For = time.Duration(params.Days) * 24 * time.Hour
+	}
+
+	user, err := cfg.Database.SuspendUser(userID, adminID, suspension)
+	if err != nil {
+		respondWithSuspensionError(w, err)
+		return
+	}
+	cfg.Scheduler.Wake()
+	httphandler.RespondWithJSON(w, http.StatusOK, user)
+}
+
+// LiftSuspensionHandler ends a suspension or ban early.
+func (cfg *ApiConfig) LiftSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Note string `json:"note"`
+	}
+
+	adminID, ok := cfg.confirmRole(w, r, database.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, "User ID must be an integer")
+		return
+	}
+
+	params := parameters{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err = decoder.Decode(&params)
+		if err != nil {
+			httphandler.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+			return
+		}
+	}
+
+	user, err := cfg.Database.LiftSuspension(userID, adminID, params.Note)
+	if err != nil {
+		respondWithSuspensionError(w, err)
+		return
+	}
+	// Scheduled chirps held back by the suspension are due now.
+	cfg.Scheduler.Wake()
+	httphandler.RespondWithJSON(w, http.StatusOK, user)
+}
+
+func respondWithSuspensionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		httphandler.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
+	case errors.Is(err, database.ErrSuspendAdmin):
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+	case errors.Is(err, database.ErrUserNotSuspended):
+		httphandler.RespondWithError(w, http.StatusConflict, fmt.Sprintf("%s", err))
+	default:
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+	}
+}
+
+// suspensionMessage tells a suspended user why they can't log in or post,
+// and until when.
+func suspensionMessage(user database.User) string {
+	message := "Account is banned"
+	if !user.Banned {
+		message = "Account is suspended until " + user.SuspendedUntil.Format(time.RFC3339)
+	}
+	if user.SuspensionReason != "" {
+		message += ": " + user.SuspensionReason
+	}
+	return message
+}
@@ -0,0 +1,63 @@
+package apiconfig
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// GetTimelineHandler returns the caller's home timeline: their own chirps
+// and those of the users they follow, newest first.
+func (cfg *ApiConfig) GetTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateAccessToken(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%s", err))
+		return
+	}
+
+	followed, err := cfg.Database.GetFollowedUsers(userID)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	authorIDs := []int{userID}
+	for _, user := range followed {
+		authorIDs = append(authorIDs, user.ID)
+	}
+	cfg.respondWithTimeline(w, r, userID, authorIDs, fmt.Sprintf("timeline:%d", userID))
+}
+
+// GetListChirpsHandler returns a list's timeline, built the same way as the
+// home timeline over the list's members.
+func (cfg *ApiConfig) GetListChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	listID, ok := listIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	viewerID := cfg.viewerID(r)
+	list, err := cfg.Database.GetList(listID, viewerID)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+	cfg.respondWithTimeline(w, r, viewerID, list.MemberIDs, fmt.Sprintf("list:%d", listID))
+}
+
+func (cfg *ApiConfig) respondWithTimeline(
+	w http.ResponseWriter,
+	r *http.Request,
+	viewerID int,
+	authorIDs []int,
+	scope string,
+) {
+	query := database.ChirpQuery{
+		ViewerID:  viewerID,
+		AuthorIDs: authorIDs,
+		SortField: "created_at",
+		SortDesc:  true,
+	}
+	cfg.respondWithChirpPage(w, r, query, scope)
+}
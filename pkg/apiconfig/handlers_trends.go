@@ -0,0 +1,83 @@
+package apiconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/internal/trends"
+	"github.com/AxterDoesCode/webserver/pkg/httphandler"
+)
+
+// GetTrendsHandler serves the cached trends for the window query parameter,
+// 1h by default. Chirps the viewer can't see are left out.
+func (cfg *ApiConfig) GetTrendsHandler(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "1h"
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	snapshot, err := cfg.Trends.Trends(window)
+	if errors.Is(err, trends.ErrUnknownWindow) {
+		httphandler.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s", err))
+		return
+	}
+
+	type trendingChirp struct {
+		Chirp       database.Chirp `json:"chirp"`
+		Score       float64        `json:"score"`
+		Engagements int            `json:"engagements"`
+	}
+	type trendsResponse struct {
+		Window     string            `json:"window"`
+		ComputedAt time.Time         `json:"computed_at"`
+		Hashtags   []trends.TagTrend `json:"hashtags"`
+		Chirps     []trendingChirp   `json:"chirps"`
+	}
+
+	res := trendsResponse{
+		Window:     snapshot.Window,
+		ComputedAt: snapshot.ComputedAt,
+		Hashtags:   snapshot.Tags,
+		Chirps:     make([]trendingChirp, 0),
+	}
+	if len(res.Hashtags) > limit {
+		res.Hashtags = res.Hashtags[:limit]
+	}
+
+	ids := make([]int, 0, len(snapshot.Chirps))
+	for _, trend := range snapshot.Chirps {
+		ids = append(ids, trend.ChirpID)
+	}
+	chirps, err := cfg.Database.GetChirpsByIDs(ids, cfg.viewerID(r))
+	if err != nil {
+		httphandler.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+	visible := make(map[int]database.Chirp, len(chirps))
+	for _, chirp := range chirps {
+		visible[chirp.ID] = chirp
+	}
+	for _, trend := range snapshot.Chirps {
+		if len(res.Chirps) == limit {
+			break
+		}
+		chirp, ok := visible[trend.ChirpID]
+		if !ok {
+			continue
+		}
+		res.Chirps = append(res.Chirps, trendingChirp{
+			Chirp:       chirp,
+			Score:       trend.Score,
+			Engagements: trend.Engagements,
+		})
+	}
+	httphandler.RespondWithJSON(w, http.StatusOK, res)
+}
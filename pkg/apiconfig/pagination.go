@@ -0,0 +1,125 @@
+package apiconfig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/database"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+var errInvalidCursor = errors.New("Cursor is invalid")
+
+// pageCursor is the position of the last item of a page. Scope ties the
+// cursor to the listing and ordering that produced it so that it can't be
+// replayed against a different one.
+type pageCursor struct {
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	ID        int       `json:"id"`
+}
+
+// encodeCursor serializes and signs a cursor. Clients treat the result as an
+// opaque string.
+func (cfg *ApiConfig) encodeCursor(cursor pageCursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + cfg.cursorSignature(encoded), nil
+}
+
+// decodeCursor verifies a cursor produced by encodeCursor for scope.
+func (cfg *ApiConfig) decodeCursor(value, scope string) (pageCursor, error) {
+	encoded, signature, found := strings.Cut(value, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(cfg.cursorSignature(encoded))) {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	cursor := pageCursor{}
+	err = json.Unmarshal(payload, &cursor)
+	if err != nil || cursor.Scope != scope {
+		return pageCursor{}, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+// cursorPosition decodes the request's cursor parameter for scope. It
+// returns nil when the request asks for the first page.
+func (cfg *ApiConfig) cursorPosition(r *http.Request, scope string) (*database.Position, error) {
+	value := r.URL.Query().Get("cursor")
+	if value == "" {
+		return nil, nil
+	}
+	cursor, err := cfg.decodeCursor(value, scope)
+	if err != nil {
+		return nil, err
+	}
+	return &database.Position{CreatedAt: cursor.CreatedAt, ID: cursor.ID}, nil
+}
+
+// nextCursor encodes position as the cursor for the page after it, or
+// returns "" when there is no next page.
+func (cfg *ApiConfig) nextCursor(scope string, position *database.Position) (string, error) {
+	if position == nil {
+		return "", nil
+	}
+	return cfg.encodeCursor(pageCursor{
+		Scope:     scope,
+		CreatedAt: position.CreatedAt,
+		ID:        position.ID,
+	})
+}
+
+func (cfg *ApiConfig) cursorSignature(encoded string) string {
+	mac := hmac.New(sha256.New, []byte("chirpy-cursor:"+cfg.JwtSecret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseLimit reads the limit query parameter, clamped to maxPageLimit.
+func parseLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
+
+// setNextLink advertises the next page with an RFC 8288 Link header that
+// repeats the request's query with the cursor replaced.
+func setNextLink(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", nextCursor)
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
@@ -1,7 +1,14 @@
 package apiconfig
 
 import (
+	"time"
+
+	"github.com/AxterDoesCode/webserver/internal/audit"
+	"github.com/AxterDoesCode/webserver/internal/blobstore"
+	"github.com/AxterDoesCode/webserver/internal/contentfilter"
 	"github.com/AxterDoesCode/webserver/internal/database"
+	"github.com/AxterDoesCode/webserver/internal/scheduler"
+	"github.com/AxterDoesCode/webserver/internal/trends"
 )
 
 type ApiConfig struct {
@@ -9,4 +16,29 @@ type ApiConfig struct {
 	Database       database.DB
 	JwtSecret      string
 	PolkaKey       string
+	// ChirpMaxLength and ChirpMaxLengthRed are the longest chirps regular
+	// and Chirpy Red users may post, as measured by chirptext.Length.
+	ChirpMaxLength    int
+	ChirpMaxLengthRed int
+	// PinLimit and PinLimitRed are how many chirps regular and Chirpy Red
+	// users may pin.
+	PinLimit    int
+	PinLimitRed int
+	// ChirpEditWindow is how long after posting a chirp can be edited; 0
+	// allows edits at any time.
+	ChirpEditWindow time.Duration
+	// ChirpEditRequiresRed limits editing to Chirpy Red members.
+	ChirpEditRequiresRed bool
+	// Trends serves the cached results of the trends aggregator.
+	Trends *trends.Aggregator
+	// ContentFilter masks, rejects or flags chirp bodies.
+	ContentFilter contentfilter.ContentFilter
+	// BlobStore holds uploaded media, which may be at most MediaMaxBytes.
+	BlobStore     blobstore.BlobStore
+	MediaMaxBytes int64
+	// Scheduler publishes scheduled chirps, closes expired polls and lifts
+	// expired suspensions. It is woken when any of them changes.
+	Scheduler *scheduler.Scheduler
+	// Audit records security-relevant events.
+	Audit *audit.Log
 }